@@ -1,18 +1,21 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
 	"os"
-	"strconv"
-	"time"
+	"sort"
 	"utopia/internal/chain"
 	"utopia/internal/config"
+	"utopia/internal/contract"
+	"utopia/internal/database"
 	"utopia/internal/helper"
 	"utopia/internal/wallet"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -53,6 +56,47 @@ var (
 		Usage: "The excel file path with address list",
 		Value: "",
 	}
+	WaitFlag = cli.BoolFlag{
+		Name:  "wait",
+		Usage: "Wait transaction receipt and report final status",
+	}
+	ConfirmFlag = cli.Uint64Flag{
+		Name:  "confirm",
+		Usage: "The number of blocks to confirm transaction",
+		Value: 1,
+	}
+	AllFlag = cli.BoolFlag{
+		Name:  "all",
+		Usage: "Use all loaded accounts",
+	}
+	DryRunFlag = cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "Simulate transaction on pending block without sending it",
+	}
+	DetailFlag = cli.BoolFlag{
+		Name:  "detail",
+		Usage: "Show total cost of transfer include L1 data fee",
+	}
+	DatabaseFlag = cli.StringFlag{
+		Name:  "db",
+		Usage: "The sqlite database file path",
+		Value: "./utopia.db",
+	}
+	SelectorFlag = cli.StringFlag{
+		Name:  "db",
+		Usage: "The sqlite database file path of function selectors, not used if empty",
+		Value: "",
+	}
+	StartFlag = cli.Uint64Flag{
+		Name:  "start",
+		Usage: "The start block number if no checkpoint, latest confirmed block if not set",
+		Value: 0,
+	}
+	BumpFlag = cli.Uint64Flag{
+		Name:  "bump",
+		Usage: "The minimum fee bump percent of replacement",
+		Value: chain.DEFAULT_PRICE_BUMP,
+	}
 
 	cmdBalance = cli.Command{
 		Name:   "balance",
@@ -60,6 +104,8 @@ var (
 		Action: QueryBalance,
 		Flags: []cli.Flag{
 			AddressFlag,
+			FileFlag,
+			AllFlag,
 		},
 	}
 	cmdTransfer = cli.Command{
@@ -70,15 +116,47 @@ var (
 			ToFlag,
 			ValueFlag,
 			FileFlag,
+			WaitFlag,
+			ConfirmFlag,
+			DryRunFlag,
 		},
 	}
 	cmdSpeedup = cli.Command{
 		Name:   "speedup",
-		Usage:  "Speedup transaction on chain",
+		Usage:  "Speedup pending transaction by hash or all pending transactions of address",
 		Action: Speedup,
 		Flags: []cli.Flag{
 			HashFlag,
+			AddressFlag,
+			GasPriceFlag,
+			BumpFlag,
+			WaitFlag,
+			ConfirmFlag,
+			SelectorFlag,
+		},
+	}
+	cmdCancel = cli.Command{
+		Name:   "cancel",
+		Usage:  "Cancel pending transaction by hash or all pending transactions of address",
+		Action: Cancel,
+		Flags: []cli.Flag{
+			HashFlag,
+			AddressFlag,
 			GasPriceFlag,
+			BumpFlag,
+			WaitFlag,
+			ConfirmFlag,
+			SelectorFlag,
+		},
+	}
+	cmdScan = cli.Command{
+		Name:   "scan",
+		Usage:  "Scan blocks and save transfers of loaded accounts",
+		Action: ScanBlocks,
+		Flags: []cli.Flag{
+			DatabaseFlag,
+			StartFlag,
+			ConfirmFlag,
 		},
 	}
 	cmdRpcServer = cli.Command{
@@ -95,12 +173,19 @@ var (
 		Action: QueryGas,
 		Flags: []cli.Flag{
 			ChainFlag,
+			DetailFlag,
 		},
 	}
 )
 
 func QueryBalance(ctx *cli.Context) error {
 	address := ctx.String(AddressFlag.Name)
+	path := ctx.String(FileFlag.Name)
+	all := ctx.Bool(AllFlag.Name)
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
 
 	// get chain meta and connect it
 	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
@@ -108,18 +193,65 @@ func QueryBalance(ctx *cli.Context) error {
 		return err
 	}
 
-	c := chain.NewChain(meta.Id, meta.Currency, meta.Name)
-	if c == nil {
-		return errors.New("Connect chain failed")
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
 	}
 	defer c.DisConnect()
 
-	balance, err := c.Balance(address)
-	if err != nil {
-		return err
+	if path == "" && !all {
+		balance, err := c.Balance(cctx, address)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Address[%s].balance=%s\n", address, helper.FormatAmount(balance, meta.Decimal()))
+		return nil
+	}
+
+	// address list from file or loaded accounts
+	addresses := make([]string, 0)
+	if path != "" {
+		addresses, err = wallet.ReadAddressList(path)
+		if err != nil {
+			return err
+		}
+	} else {
+		for address := range wallet.AccountList {
+			addresses = append(addresses, address)
+		}
+		sort.Strings(addresses)
+	}
+
+	// query all balances in batch call on evm chain, or one by one
+	var balances []*big.Int
+	batchErr := &chain.BatchError{Errors: make([]error, len(addresses))}
+	if ethchain, ok := c.(*chain.EthChain); ok {
+		balances, err = ethchain.BatchBalance(cctx, addresses)
+		if e, ok := err.(*chain.BatchError); ok {
+			batchErr = e
+		} else if err != nil {
+			return err
+		}
+	} else {
+		balances = make([]*big.Int, len(addresses))
+		for i, address := range addresses {
+			balances[i], batchErr.Errors[i] = c.Balance(cctx, address)
+		}
+	}
+
+	total := new(big.Int)
+	for i, address := range addresses {
+		if balances[i] == nil {
+			fmt.Fprintf(os.Stderr, "Address[%s] query balance failed with err: %v\n", address, batchErr.Errors[i])
+			continue
+		}
+
+		total.Add(total, balances[i])
+		fmt.Printf("Address[%s].balance=%s\n", address, helper.FormatAmount(balances[i], meta.Decimal()))
 	}
 
-	fmt.Printf("Address[%s].balance=%f\n", address, helper.WeiToEth(balance))
+	fmt.Printf("Total balance of %d address is %s\n", len(addresses), helper.FormatAmount(total, meta.Decimal()))
 	return nil
 }
 
@@ -127,6 +259,13 @@ func TransferBalance(ctx *cli.Context) error {
 	to := ctx.String(ToFlag.Name)
 	value := ctx.String(ValueFlag.Name)
 	file := ctx.String(FileFlag.Name)
+	wait := ctx.Bool(WaitFlag.Name)
+	confirm := ctx.Uint64(ConfirmFlag.Name)
+	dryRun := ctx.Bool(DryRunFlag.Name)
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
 
 	translist := make([]helper.TransferInfo, 0)
 	if to != "" && value != "" {
@@ -153,34 +292,85 @@ func TransferBalance(ctx *cli.Context) error {
 		return err
 	}
 
-	c := chain.NewChain(meta.Id, meta.Currency, meta.Name)
-	if c == nil {
-		return errors.New("Connect chain failed")
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
 	}
 	defer c.DisConnect()
 
+	ethchain, ok := c.(*chain.EthChain)
+	if dryRun && !ok {
+		return errors.New("Dry run only support evm chain")
+	}
+
+	hashes := make([]string, 0, len(translist))
 	for _, info := range translist {
+		// stop the batch if interrupted
+		if cctx.Err() != nil {
+			return cctx.Err()
+		}
+
 		// get wallet for sign transaction
 		wallet, err := wallet.GetWallet(info.From)
 		if err != nil {
 			return err
 		}
 
-		fv, _ := strconv.ParseFloat(info.Value, 64)
-		tx, err := c.Transfer(info.To, helper.EthToWei(float32(fv)), wallet)
+		amount, err := helper.ParseAmount(info.Value, meta.Decimal())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Tranfer %s to %s failed with err: %v\n", info.Value, info.To, err)
+			continue
+		}
+
+		if dryRun {
+			sim, err := ethchain.DryRunTransfer(cctx, info.To, amount, wallet)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Simulate transfer %s to %s failed with err: %v\n", info.Value, info.To, err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Transfer %s to %s: %s\n", info.Value, info.To, sim)
+			}
+			continue
+		}
+
+		tx, err := c.Transfer(cctx, info.To, amount, wallet)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Tranfer %s to %s failed with err: %v\n", info.Value, info.To, err)
 		} else {
 			fmt.Fprintf(os.Stderr, "Tranfer %s to %s with transaction %s\n", info.Value, info.To, tx)
+			hashes = append(hashes, tx)
 		}
 	}
 
+	if wait {
+		return waitTransactions(cctx, c, hashes, confirm)
+	}
+
 	return nil
 }
 
 func Speedup(ctx *cli.Context) error {
+	return replaceTransactions(ctx, false)
+}
+
+func Cancel(ctx *cli.Context) error {
+	return replaceTransactions(ctx, true)
+}
+
+// replace pending transaction by hash, or all pending transactions of address
+func replaceTransactions(ctx *cli.Context, cancelTx bool) error {
 	gas := ctx.Uint64(GasPriceFlag.Name)
 	hash := ctx.String(HashFlag.Name)
+	address := ctx.String(AddressFlag.Name)
+	wait := ctx.Bool(WaitFlag.Name)
+	confirm := ctx.Uint64(ConfirmFlag.Name)
+
+	if (hash == "") == (address == "") {
+		return errors.New("Input either transaction hash or address")
+	}
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
 
 	// get chain meta and connect it
 	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
@@ -188,59 +378,232 @@ func Speedup(ctx *cli.Context) error {
 		return err
 	}
 
-	c := chain.NewChain(meta.Id, meta.Currency, meta.Name)
-	if c == nil {
-		return errors.New("Connect chain failed")
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
 	}
 	defer c.DisConnect()
 
-	// query current gasprice on chain
-	gasprice, err := c.GasPrice()
+	ethchain, ok := c.(*chain.EthChain)
+	if !ok {
+		return errors.New("Replace transaction only support evm chain")
+	}
+
+	replacer := chain.NewReplacer(ethchain)
+	replacer.PriceBump = ctx.Uint64(BumpFlag.Name)
+
+	// query current fee on chain, input gas price overrides the max price
+	fee, err := ethchain.SuggestFee(cctx)
 	if err != nil {
 		return err
 	}
 
 	if gas != 0 {
-		if gas < gasprice.Uint64() {
-			return errors.New("Input gas price is less")
+		gasprice := new(big.Int).SetUint64(gas)
+		if fee.IsDynamic() {
+			fee.GasFeeCap = gasprice
+			if fee.GasTipCap.Cmp(gasprice) > 0 {
+				fee.GasTipCap = gasprice
+			}
+		} else {
+			fee.GasPrice = gasprice
+		}
+	}
+
+	// collect pending transactions
+	txs := make([]*types.Transaction, 0)
+	if hash != "" {
+		tx, pending, err := ethchain.EthTransaction(cctx, common.FromHex(hash))
+		if err != nil {
+			return err
+		}
+
+		if !pending {
+			return errors.New("Transaction is not pending")
+		}
+
+		txs = append(txs, tx)
+	} else {
+		txs, err = replacer.Pending(cctx, address)
+		if err != nil {
+			return err
+		}
+
+		if len(txs) == 0 {
+			return errors.New("No pending transaction of address")
+		}
+	}
+
+	// decode call data of pending transactions by function selectors if database given
+	var selectors *contract.SelectorDB
+	if ctx.String(SelectorFlag.Name) != "" {
+		db := database.NewDatabase(ctx.String(SelectorFlag.Name))
+		if err = db.Open(); err == nil {
+			defer db.Close()
+			selectors, err = contract.NewSelectorDB(db)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Open selector database failed with err: %v\n", err)
+		}
+	}
+
+	// resend transactions with higher fee by the wallet of sender
+	hashes := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		if cctx.Err() != nil {
+			return cctx.Err()
+		}
+
+		fmt.Printf("Pending transaction %s nonce %d %s\n", tx.Hash().Hex(), tx.Nonce(), describeCall(selectors, tx))
+
+		from, err := replacer.Sender(tx)
+		if err != nil {
+			return err
+		}
+
+		w, err := wallet.GetWallet(from.Hex())
+		if err != nil {
+			return err
+		}
+
+		var replace *types.Transaction
+		if cancelTx {
+			replace, err = replacer.Cancel(cctx, tx, fee, w)
+		} else {
+			replace, err = replacer.SpeedUp(cctx, tx, fee, w)
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Replace transaction %s nonce %d failed with err: %v\n", tx.Hash().Hex(), tx.Nonce(), err)
+			continue
+		}
+
+		fmt.Printf("Replace transaction %s nonce %d with %s\n", tx.Hash().Hex(), tx.Nonce(), replace.Hash().Hex())
+		hashes = append(hashes, replace.Hash().Hex())
+	}
+
+	if wait {
+		return waitTransactions(cctx, c, hashes, confirm)
+	}
+
+	return nil
+}
+
+// describe pending transaction with decoded call if its selector is known
+func describeCall(selectors *contract.SelectorDB, tx *types.Transaction) string {
+	to := "create"
+	if tx.To() != nil {
+		to = "to " + tx.To().Hex()
+	}
+
+	if len(tx.Data()) == 0 {
+		return fmt.Sprintf("%s value %s", to, tx.Value().String())
+	}
+
+	if selectors != nil {
+		call, err := selectors.Decode(tx.Data())
+		if err == nil {
+			return fmt.Sprintf("%s call %s", to, call)
 		}
+	}
 
-		gasprice = new(big.Int).SetUint64(gas)
+	// unknown selector
+	data := tx.Data()
+	if len(data) > 4 {
+		data = data[:4]
 	}
 
-	// query pending tx
-	tx, pending, err := c.Transaction(common.FromHex(hash))
+	return fmt.Sprintf("%s data %s", to, hexutil.Encode(data))
+}
+
+func ScanBlocks(ctx *cli.Context) error {
+	path := ctx.String(DatabaseFlag.Name)
+	start := ctx.Uint64(StartFlag.Name)
+	confirm := ctx.Uint64(ConfirmFlag.Name)
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
+	// get chain meta and connect it
+	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
 	if err != nil {
 		return err
 	}
 
-	if !pending {
-		return errors.New("Transaction is not pending")
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
 	}
+	defer c.DisConnect()
 
-	if gasprice.Cmp(tx.GasPrice()) < 0 {
-		return errors.New("Input gas less than transaction gas")
+	ethchain, ok := c.(*chain.EthChain)
+	if !ok {
+		return errors.New("Scan only support evm chain")
 	}
 
-	// get wallet for sign transaction
-	wallet, err := wallet.GetWallet(config.Config.Chain.From)
+	db := database.NewDatabase(path)
+	err = db.Open()
 	if err != nil {
 		return err
 	}
+	defer db.Close()
 
-	// resend transaction with higher gas
-	hash, err = c.SendTransaction(types.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), tx.Gas(), gasprice, tx.Data()), wallet)
+	scanner, err := chain.NewScanner(ethchain, db, "transfer")
 	if err != nil {
 		return err
 	}
+	scanner.Start = start
+	scanner.Confirmations = confirm
 
-	fmt.Printf("Speed up transaction %s", hash)
-	return nil
+	// start from latest confirmed block if no checkpoint and start not set
+	_, ok, err = scanner.Checkpoint()
+	if err != nil {
+		return err
+	}
+
+	if !ok && !ctx.IsSet(StartFlag.Name) {
+		latest, err := ethchain.BlockNumber(cctx)
+		if err != nil {
+			return err
+		}
+
+		if latest > confirm {
+			scanner.Start = latest - confirm
+		}
+	}
+
+	// transfers of all loaded accounts
+	accounts := make([]string, 0, len(wallet.AccountList))
+	for address := range wallet.AccountList {
+		accounts = append(accounts, address)
+	}
+
+	handler, err := chain.NewTransferHandler(ethchain, db, accounts)
+	if err != nil {
+		return err
+	}
+
+	handler.OnTransfer = func(number uint64, tx *types.Transaction, from common.Address) {
+		fmt.Printf("[%d][%s] %s -> %s value=%s\n", number, tx.Hash().Hex(), from.Hex(), tx.To().Hex(), helper.FormatAmount(tx.Value(), meta.Decimal()))
+	}
+	scanner.AddHandler(handler)
+
+	err = scanner.Run(cctx)
+	if err == context.Canceled {
+		return nil
+	}
+
+	return err
 }
 
 func ListRpc(ctx *cli.Context) error {
 	chainName := ctx.String(ChainFlag.Name)
 
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
 	metaList := make([]chain.ChainMeta, 0)
 	if chainName == "" {
 		metaList = chain.ChainList
@@ -257,24 +620,25 @@ func ListRpc(ctx *cli.Context) error {
 		return errors.New("Empty chain list")
 	}
 
-	// ping all rpc server
+	// probe all rpc server and print health status, the chain is not connected so the down servers are listed too
 	for _, meta := range metaList {
-		chain := chain.NewChain(meta.Id, meta.Currency, meta.Name)
-		if chain == nil {
-			return errors.New("Connect chain failed")
+		if meta.ChainType() != chain.CHAIN_TYPE_EVM {
+			continue
 		}
-		defer chain.DisConnect()
 
-		for index, url := range meta.RpcServer {
-			start := time.Now()
+		ethchain, ok := chain.NewEthChain(meta.Id, meta.Currency, meta.Name).(*chain.EthChain)
+		if !ok {
+			fmt.Printf("[%s] create chain failed\n", meta.Name)
+			continue
+		}
 
-			err := chain.Connect([]string{url}, true)
-			if err != nil {
-				continue
+		for index, h := range ethchain.Probe(cctx) {
+			status := "healthy"
+			if !h.Healthy {
+				status = "unhealthy: " + h.LastError
 			}
 
-			latency := time.Now().Sub(start)
-			fmt.Printf("[%s][%d] url=%s, latency=%d ms\n", meta.Name, index, url, latency.Milliseconds())
+			fmt.Printf("[%s][%d] url=%s, latency=%d ms, errors=%d/%d, %s\n", meta.Name, index, h.Url, h.Latency.Milliseconds(), h.Failure, h.Success+h.Failure, status)
 		}
 	}
 
@@ -283,6 +647,11 @@ func ListRpc(ctx *cli.Context) error {
 
 func QueryGas(ctx *cli.Context) error {
 	chainName := ctx.String(ChainFlag.Name)
+	detail := ctx.Bool(DetailFlag.Name)
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
 
 	metaList := make([]chain.ChainMeta, 0)
 	if chainName == "" {
@@ -300,20 +669,74 @@ func QueryGas(ctx *cli.Context) error {
 		return errors.New("Empty chain list")
 	}
 
-	// query gas from all chains
+	// query gas from all chains, the failed chain is skipped if not specified
 	for _, meta := range metaList {
-		chain := chain.NewChain(meta.Id, meta.Currency, meta.Name)
-		if chain == nil {
-			return errors.New("Connect chain failed")
+		c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+		if err != nil {
+			if chainName != "" {
+				return err
+			}
+
+			fmt.Printf("[%s] connect chain failed: %v\n", meta.Name, err)
+			continue
 		}
-		defer chain.DisConnect()
+		defer c.DisConnect()
 
-		gasprice, err := chain.GasPrice()
+		fee, err := c.SuggestFeeRate(cctx)
 		if err != nil {
-			return err
+			if chainName != "" {
+				return err
+			}
+
+			fmt.Printf("[%s] query gas failed: %v\n", meta.Name, err)
+			continue
+		}
+
+		fmt.Printf("Gas %s %s on chain %s\n", fee.Price.String(), fee.Unit, meta.Name)
+
+		// total cost of native transfer include L1 data fee
+		ethchain, ok := c.(*chain.EthChain)
+		if !detail || !ok {
+			continue
+		}
+
+		receiver := common.Address{}
+		cost, err := ethchain.EstimateCost(cctx, ethchain.NewTransaction(0, &receiver, big.NewInt(0), 21000, fee.Eth(), nil))
+		if err != nil {
+			if chainName != "" {
+				return err
+			}
+
+			fmt.Printf("[%s] estimate transfer cost failed: %v\n", meta.Name, err)
+			continue
 		}
 
-		fmt.Printf("Gas %d on chain %s\n", gasprice.Uint64(), meta.Name)
+		fmt.Printf("  transfer gas=%d, price=%s, l2fee=%s, l1fee=%s, total=%s %s\n", cost.GasLimit, cost.GasPrice.String(),
+			helper.FormatAmount(cost.L2Fee, meta.Decimal()), helper.FormatAmount(cost.L1Fee, meta.Decimal()), helper.FormatAmount(cost.Total, meta.Decimal()), meta.Currency)
+	}
+
+	return nil
+}
+
+// wait transactions until final status and print the result
+func waitTransactions(cctx context.Context, c chain.Chain, hashes []string, confirm uint64) error {
+	ethchain, ok := c.(*chain.EthChain)
+	if !ok {
+		return errors.New("Wait only support evm chain")
+	}
+
+	tracker := chain.NewTracker(ethchain, confirm)
+	for _, hash := range hashes {
+		result, err := tracker.Wait(cctx, hash)
+		if cctx.Err() != nil {
+			return cctx.Err()
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Wait transaction %s failed with err: %v\n", hash, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s\n", result)
+		}
 	}
 
 	return nil
@@ -21,6 +21,8 @@ func init() {
 		cmdBalance,
 		cmdTransfer,
 		cmdSpeedup,
+		cmdCancel,
+		cmdScan,
 		cmdRpcServer,
 		cmdGas,
 	}
@@ -1,19 +1,25 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"utopia/internal/chain"
 	"utopia/internal/config"
 	"utopia/internal/contract"
+	"utopia/internal/database"
 	"utopia/internal/helper"
-	utopia_network "utopia/internal/network"
 	"utopia/internal/wallet"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -51,7 +57,7 @@ var (
 	}
 	ParamFlag = cli.StringFlag{
 		Name:  "param",
-		Usage: "The parameters for call contract",
+		Usage: "The parameters for call contract, like f(0x01,-1,[1,2],(0x02,\"a\")), array in [] and tuple in ()",
 		Value: "",
 	}
 	DataFlag = cli.StringFlag{
@@ -75,7 +81,83 @@ var (
 	}
 	EnableFlag = cli.BoolFlag{
 		Name:  "enable",
-		Usage: "Enable approve or Disable appreove",
+		Usage: "Approve all tokens by setApprovalForAll, --enable=false to revoke",
+	}
+	TypeFlag = cli.StringFlag{
+		Name:  "type",
+		Usage: "The contract type: erc20, erc721, erc1155 or empty with abi file",
+		Value: "",
+	}
+	EventFlag = cli.StringFlag{
+		Name:  "event",
+		Usage: "The event name, empty for all events",
+		Value: "",
+	}
+	StartFlag = cli.Uint64Flag{
+		Name:  "start",
+		Usage: "The start block number",
+		Value: 0,
+	}
+	EndFlag = cli.Uint64Flag{
+		Name:  "end",
+		Usage: "The end block number, 0 for latest block",
+		Value: 0,
+	}
+	WaitFlag = cli.BoolFlag{
+		Name:  "wait",
+		Usage: "Wait transaction receipt and report final status",
+	}
+	ConfirmFlag = cli.Uint64Flag{
+		Name:  "confirm",
+		Usage: "The number of blocks to confirm transaction",
+		Value: 1,
+	}
+	JsonFlag = cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print result in json format",
+	}
+	DryRunFlag = cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "Simulate transaction on pending block without sending it",
+	}
+	IdFlag = cli.StringFlag{
+		Name:  "id",
+		Usage: "The token id, multiple ids are separated by comma and range is like 1-100",
+		Value: "",
+	}
+	SafeFlag = cli.BoolFlag{
+		Name:  "safe",
+		Usage: "Transfer nft by safeTransferFrom with data",
+	}
+	DatabaseFlag = cli.StringFlag{
+		Name:  "db",
+		Usage: "The sqlite database file path of function selectors and nft metadata cache",
+		Value: "./utopia.db",
+	}
+	SelectorFlag = cli.StringFlag{
+		Name:  "db",
+		Usage: "The sqlite database file path of function selectors, not used if empty",
+		Value: "",
+	}
+	SignatureFlag = cli.StringFlag{
+		Name:  "signatures",
+		Usage: "The signature list file path, one function signature each line",
+		Value: "",
+	}
+	DeadlineFlag = cli.Uint64Flag{
+		Name:  "deadline",
+		Usage: "The seconds from now the permit is valid",
+		Value: 3600,
+	}
+	RelayerFlag = cli.StringFlag{
+		Name:  "relayer",
+		Usage: "The account submits permit and pays gas, the permit is only signed if empty",
+		Value: "",
+	}
+	PermitFlag = cli.StringFlag{
+		Name:  "permit",
+		Usage: "The signed permit json file path to submit",
+		Value: "",
 	}
 
 	cmdDeploy = cli.Command{
@@ -87,6 +169,10 @@ var (
 			ABIFlag,
 			ParamFlag,
 			ValueFlag,
+			WaitFlag,
+			ConfirmFlag,
+			DryRunFlag,
+			SelectorFlag,
 		},
 	}
 	cmdCall = cli.Command{
@@ -98,6 +184,11 @@ var (
 			ABIFlag,
 			ParamFlag,
 			ValueFlag,
+			WaitFlag,
+			ConfirmFlag,
+			DryRunFlag,
+			JsonFlag,
+			SelectorFlag,
 		},
 	}
 	cmdList = cli.Command{
@@ -130,6 +221,9 @@ var (
 					ToFlag,
 					ValueFlag,
 					FileFlag,
+					WaitFlag,
+					ConfirmFlag,
+					DryRunFlag,
 				},
 			},
 			{
@@ -142,6 +236,40 @@ var (
 					ValueFlag,
 				},
 			},
+			{
+				Name:   "info",
+				Usage:  "Query name, symbol, decimals and total supply of erc20 contract",
+				Action: InfoERC20,
+				Flags: []cli.Flag{
+					ContractFlag,
+				},
+			},
+			{
+				Name:   "allowance",
+				Usage:  "Query erc20 amount of account approved to spender",
+				Action: AllowanceERC20,
+				Flags: []cli.Flag{
+					ContractFlag,
+					AccountFlag,
+					ToFlag,
+				},
+			},
+			{
+				Name:   "permit",
+				Usage:  "Sign erc20 permit of account and submit it by relayer",
+				Action: PermitERC20,
+				Flags: []cli.Flag{
+					ContractFlag,
+					ToFlag,
+					ValueFlag,
+					DeadlineFlag,
+					RelayerFlag,
+					PermitFlag,
+					FileFlag,
+					WaitFlag,
+					ConfirmFlag,
+				},
+			},
 		},
 	}
 	cmdERC721 = cli.Command{
@@ -166,11 +294,24 @@ var (
 					ToFlag,
 					ValueFlag,
 					FileFlag,
+					DryRunFlag,
+					SafeFlag,
+					DataFlag,
+				},
+			},
+			{
+				Name:   "inventory",
+				Usage:  "List erc721 tokens owned by all accounts and export to excel file, start is required if not enumerable",
+				Action: InventoryERC721,
+				Flags: []cli.Flag{
+					ContractFlag,
+					StartFlag,
+					FileFlag,
 				},
 			},
 			{
 				Name:   "approve",
-				Usage:  "Approve erc721 balance of account",
+				Usage:  "Approve erc721 token by id, or all tokens to operator if enable is set",
 				Action: ApproveERC721,
 				Flags: []cli.Flag{
 					ContractFlag,
@@ -186,10 +327,87 @@ var (
 				Flags: []cli.Flag{
 					ContractFlag,
 					ValueFlag,
+					DatabaseFlag,
+				},
+			},
+			{
+				Name:   "traits",
+				Usage:  "Export traits and rarity scores of erc721 collection to excel file",
+				Action: TraitsERC721,
+				Flags: []cli.Flag{
+					ContractFlag,
+					IdFlag,
+					FileFlag,
+					DatabaseFlag,
+				},
+			},
+		},
+	}
+	cmdERC1155 = cli.Command{
+		Name:  "erc1155",
+		Usage: "ERC1155 operations on chain",
+		Subcommands: []cli.Command{
+			{
+				Name:   "balance",
+				Usage:  "Query erc1155 token balances of account",
+				Action: QueryERC1155,
+				Flags: []cli.Flag{
+					ContractFlag,
+					AccountFlag,
+					IdFlag,
+				},
+			},
+			{
+				Name:   "transfer",
+				Usage:  "Transfer erc1155 tokens, the tokens with same sender and receiver in file are sent in batch",
+				Action: TransferERC1155,
+				Flags: []cli.Flag{
+					ContractFlag,
+					ToFlag,
+					IdFlag,
+					ValueFlag,
+					FileFlag,
+					DataFlag,
+					WaitFlag,
+					ConfirmFlag,
+				},
+			},
+			{
+				Name:   "approve",
+				Usage:  "Approve all erc1155 tokens of account to operator",
+				Action: ApproveERC1155,
+				Flags: []cli.Flag{
+					ContractFlag,
+					ToFlag,
+					EnableFlag,
+				},
+			},
+			{
+				Name:   "uri",
+				Usage:  "Query metadata uri of erc1155 token",
+				Action: UriERC1155,
+				Flags: []cli.Flag{
+					ContractFlag,
+					IdFlag,
 				},
 			},
 		},
 	}
+	cmdEvents = cli.Command{
+		Name:   "events",
+		Usage:  "Query and decode contract events, print or export to excel file",
+		Action: QueryEvents,
+		Flags: []cli.Flag{
+			ContractFlag,
+			TypeFlag,
+			ABIFlag,
+			EventFlag,
+			StartFlag,
+			EndFlag,
+			FileFlag,
+			SelectorFlag,
+		},
+	}
 	cmdAbi = cli.Command{
 		Name:  "abi",
 		Usage: "ABI encode and decode",
@@ -206,12 +424,51 @@ var (
 			},
 			{
 				Name:   "decode",
-				Usage:  "Decode abi with arguments",
+				Usage:  "Decode abi with arguments, the function is found by selector if not set",
 				Action: DecodeABI,
 				Flags: []cli.Flag{
 					FuncFlag,
 					DataFlag,
 					SignFlag,
+					DatabaseFlag,
+				},
+			},
+			{
+				Name:   "import",
+				Usage:  "Import function signatures from signature list file or abi file",
+				Action: ImportSignatures,
+				Flags: []cli.Flag{
+					SignatureFlag,
+					ABIFlag,
+					DatabaseFlag,
+				},
+			},
+		},
+	}
+	cmdApproval = cli.Command{
+		Name:  "approval",
+		Usage: "Token approvals of accounts",
+		Subcommands: []cli.Command{
+			{
+				Name:   "scan",
+				Usage:  "Scan outstanding erc20 allowances and nft operators of accounts, multiple contracts are separated by comma, start is required if no contract",
+				Action: ScanApprovals,
+				Flags: []cli.Flag{
+					ContractFlag,
+					StartFlag,
+					FileFlag,
+				},
+			},
+			{
+				Name:   "revoke",
+				Usage:  "Revoke approvals in excel file by owner accounts, filtered by contract and spender if set",
+				Action: RevokeApprovals,
+				Flags: []cli.Flag{
+					FileFlag,
+					ContractFlag,
+					ToFlag,
+					WaitFlag,
+					ConfirmFlag,
 				},
 			},
 		},
@@ -223,6 +480,22 @@ func DeployContract(ctx *cli.Context) error {
 	abi := ctx.String(ABIFlag.Name)
 	params := ctx.String(ParamFlag.Name)
 	ivalue := ctx.String(ValueFlag.Name)
+	wait := ctx.Bool(WaitFlag.Name)
+	confirm := ctx.Uint64(ConfirmFlag.Name)
+	dryRun := ctx.Bool(DryRunFlag.Name)
+
+	// record abi methods for decoding call data if database given
+	if ctx.String(SelectorFlag.Name) != "" {
+		db, err := openSelectors(ctx.String(SelectorFlag.Name))
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+	}
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
 
 	value := common.Big0
 	fv, err := strconv.ParseFloat(ivalue, 64)
@@ -242,9 +515,9 @@ func DeployContract(ctx *cli.Context) error {
 		return err
 	}
 
-	chain := chain.NewChain(meta.Id, meta.Currency, meta.Name)
-	if chain == nil {
-		return errors.New("Connect chain failed")
+	chain, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
 	}
 	defer chain.DisConnect()
 
@@ -260,13 +533,27 @@ func DeployContract(ctx *cli.Context) error {
 		return err
 	}
 
+	if dryRun {
+		sim, err := contract.DryRunDeploy(cctx, string(bin), params, wallet, value)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "%s\n", sim)
+		return nil
+	}
+
 	// deploy contract
-	result, err := contract.Deploy(string(bin), params, wallet, value)
+	result, hash, err := contract.Deploy(cctx, string(bin), params, wallet, value)
 	if err != nil {
 		return err
 	}
 
-	fmt.Fprintf(os.Stderr, "Deploy contract address %s\n", result)
+	fmt.Fprintf(os.Stderr, "Deploy contract address %s with transaction %s\n", result, hash)
+	if wait {
+		return waitTransactions(cctx, chain, []string{hash}, confirm)
+	}
+
 	return nil
 }
 
@@ -275,6 +562,23 @@ func CallContract(ctx *cli.Context) error {
 	abi := ctx.String(ABIFlag.Name)
 	params := ctx.String(ParamFlag.Name)
 	ivalue := ctx.String(ValueFlag.Name)
+	wait := ctx.Bool(WaitFlag.Name)
+	confirm := ctx.Uint64(ConfirmFlag.Name)
+	dryRun := ctx.Bool(DryRunFlag.Name)
+	jsonOutput := ctx.Bool(JsonFlag.Name)
+
+	// record abi methods for decoding call data if database given
+	if ctx.String(SelectorFlag.Name) != "" {
+		db, err := openSelectors(ctx.String(SelectorFlag.Name))
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+	}
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
 
 	value := common.Big0
 	fv, err := strconv.ParseFloat(ivalue, 64)
@@ -294,26 +598,56 @@ func CallContract(ctx *cli.Context) error {
 		return err
 	}
 
-	chain := chain.NewChain(meta.Id, meta.Currency, meta.Name)
-	if chain == nil {
-		return errors.New("Connect chain failed")
+	chain, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
 	}
 	defer chain.DisConnect()
 
 	// create contract
-	contract := contract.NewContract(chain, address, contract.COMMON_CRONTACT)
-	err = contract.SetABI(abi)
+	c := contract.NewContract(chain, address, contract.COMMON_CRONTACT)
+	err = c.SetABI(abi)
 	if err != nil {
 		return err
 	}
 
+	if dryRun {
+		sim, err := c.DryRunCall(cctx, params, wallet, value)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "%s\n", sim)
+		return nil
+	}
+
 	// call contract
-	result, err := contract.Call(params, wallet, value)
+	result, err := c.Call(cctx, params, wallet, value)
 	if err != nil {
 		return err
 	}
 
-	fmt.Fprintf(os.Stderr, "Call contract result: %v\n", result)
+	// wait receipt and decode events for non-constant method
+	if wait && result.TxHash != "" {
+		fmt.Fprintf(os.Stderr, "Wait transaction %s\n", result.TxHash)
+		err = c.(*contract.EthContract).Wait(cctx, result, confirm)
+		if err != nil {
+			return err
+		}
+	}
+
+	// json result is printed to stdout for scripting
+	if jsonOutput {
+		text, err := result.JSON()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(text)
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Call contract result: %s\n", result)
 	return nil
 }
 
@@ -325,15 +659,19 @@ func QueryERC20(ctx *cli.Context) error {
 	address := ctx.String(ContractFlag.Name)
 	account := ctx.String(AccountFlag.Name)
 
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
 	// get chain meta and connect
 	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
 	if err != nil {
 		return err
 	}
 
-	c := chain.NewChain(meta.Id, meta.Currency, meta.Name)
-	if c == nil {
-		return errors.New("Connect chain failed")
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
 	}
 	defer c.DisConnect()
 
@@ -343,12 +681,91 @@ func QueryERC20(ctx *cli.Context) error {
 		return errors.New("Create erc20 contract failed")
 	}
 
-	balance, err := erc20.(*contract.ERC20Contract).Balance(account)
+	balance, err := erc20.(*contract.ERC20Contract).Balance(cctx, account)
+	if err != nil {
+		return err
+	}
+
+	amount, err := erc20.(*contract.ERC20Contract).FormatAmount(cctx, balance)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Balance: %s\n", amount)
+	return nil
+}
+
+func InfoERC20(ctx *cli.Context) error {
+	address := ctx.String(ContractFlag.Name)
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
+	// get chain meta and connect
+	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
+	if err != nil {
+		return err
+	}
+
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
+	}
+	defer c.DisConnect()
+
+	erc20 := contract.NewContract(c, address, contract.ERC20_CONTRACT)
+	if erc20 == nil {
+		return errors.New("Create erc20 contract failed")
+	}
+
+	info, err := erc20.(*contract.ERC20Contract).Info(cctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Address: %s\nName: %s\nSymbol: %s\nDecimals: %d\nTotal supply: %s\n", info.Address, info.Name, info.Symbol, info.Decimals,
+		helper.FormatAmount(info.TotalSupply, int(info.Decimals)))
+	return nil
+}
+
+func AllowanceERC20(ctx *cli.Context) error {
+	address := ctx.String(ContractFlag.Name)
+	owner := helper.DefaultVlue(ctx.String(AccountFlag.Name), config.Config.Chain.From)
+	spender := ctx.String(ToFlag.Name)
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
+	// get chain meta and connect
+	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
+	if err != nil {
+		return err
+	}
+
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
+	}
+	defer c.DisConnect()
+
+	erc20 := contract.NewContract(c, address, contract.ERC20_CONTRACT)
+	if erc20 == nil {
+		return errors.New("Create erc20 contract failed")
+	}
+
+	allowance, err := erc20.(*contract.ERC20Contract).Allowance(cctx, owner, spender)
+	if err != nil {
+		return err
+	}
+
+	amount, err := erc20.(*contract.ERC20Contract).FormatAmount(cctx, allowance)
 	if err != nil {
 		return err
 	}
 
-	fmt.Fprintf(os.Stderr, "Balance: %f\n", helper.WeiToEth(balance))
+	fmt.Fprintf(os.Stderr, "Allowance of %s to %s: %s\n", owner, spender, amount)
 	return nil
 }
 
@@ -357,6 +774,13 @@ func TransferERC20(ctx *cli.Context) error {
 	to := ctx.String(ToFlag.Name)
 	value := ctx.String(ValueFlag.Name)
 	file := ctx.String(FileFlag.Name)
+	wait := ctx.Bool(WaitFlag.Name)
+	confirm := ctx.Uint64(ConfirmFlag.Name)
+	dryRun := ctx.Bool(DryRunFlag.Name)
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
 
 	translist := make([]helper.TransferInfo, 0)
 	if to != "" && value != "" {
@@ -383,9 +807,9 @@ func TransferERC20(ctx *cli.Context) error {
 		return err
 	}
 
-	c := chain.NewChain(meta.Id, meta.Currency, meta.Name)
-	if c == nil {
-		return errors.New("Connect chain failed")
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
 	}
 	defer c.DisConnect()
 
@@ -395,22 +819,49 @@ func TransferERC20(ctx *cli.Context) error {
 		return errors.New("Create erc20 contract failed")
 	}
 
+	hashes := make([]string, 0, len(translist))
 	for _, info := range translist {
+		// stop the batch if interrupted
+		if cctx.Err() != nil {
+			return cctx.Err()
+		}
+
 		// get wallet for sign transaction
 		wallet, err := wallet.GetWallet(info.From)
 		if err != nil {
 			return err
 		}
 
-		fv, _ := strconv.ParseFloat(info.Value, 64)
-		tx, err := erc20.(*contract.ERC20Contract).Transfer(info.To, helper.EthToWei(float32(fv)), wallet)
+		// amount in decimals of token
+		amount, err := erc20.(*contract.ERC20Contract).ParseAmount(cctx, info.Value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid amount %s to %s with err: %v\n", info.Value, info.To, err)
+			continue
+		}
+
+		if dryRun {
+			sim, err := erc20.(*contract.ERC20Contract).DryRunTransfer(cctx, info.To, amount, wallet)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Simulate transfer %s to %s failed with err: %v\n", info.Value, info.To, err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Transfer %s to %s: %s\n", info.Value, info.To, sim)
+			}
+			continue
+		}
+
+		tx, err := erc20.(*contract.ERC20Contract).Transfer(cctx, info.To, amount, wallet)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Tranfer %s to %s failed with err: %v\n", info.Value, info.To, err)
 		} else {
 			fmt.Fprintf(os.Stderr, "Tranfer %s to %s with transaction %s\n", info.Value, info.To, tx)
+			hashes = append(hashes, tx)
 		}
 	}
 
+	if wait {
+		return waitTransactions(cctx, c, hashes, confirm)
+	}
+
 	return nil
 }
 
@@ -418,7 +869,10 @@ func ApproveERC20(ctx *cli.Context) error {
 	address := ctx.String(ContractFlag.Name)
 	to := ctx.String(ToFlag.Name)
 	value := ctx.String(ValueFlag.Name)
-	fv, _ := strconv.ParseFloat(value, 64)
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
 
 	// get wallet for sign transaction
 	wallet, err := wallet.GetWallet(config.Config.Chain.From)
@@ -432,9 +886,9 @@ func ApproveERC20(ctx *cli.Context) error {
 		return err
 	}
 
-	c := chain.NewChain(meta.Id, meta.Currency, meta.Name)
-	if c == nil {
-		return errors.New("Connect chain failed")
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
 	}
 	defer c.DisConnect()
 
@@ -444,7 +898,12 @@ func ApproveERC20(ctx *cli.Context) error {
 		return errors.New("Create erc20 contract failed")
 	}
 
-	tx, err := erc20.(*contract.ERC20Contract).Approve(to, helper.EthToWei(float32(fv)), wallet)
+	amount, err := erc20.(*contract.ERC20Contract).ParseAmount(cctx, value)
+	if err != nil {
+		return err
+	}
+
+	tx, err := erc20.(*contract.ERC20Contract).Approve(cctx, to, amount, wallet)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Approve %s to %s failed with err: %v\n", value, to, err)
 	} else {
@@ -454,31 +913,134 @@ func ApproveERC20(ctx *cli.Context) error {
 	return nil
 }
 
-func QueryERC721(ctx *cli.Context) error {
+func PermitERC20(ctx *cli.Context) error {
 	address := ctx.String(ContractFlag.Name)
-	account := ctx.String(AccountFlag.Name)
+	to := ctx.String(ToFlag.Name)
+	value := ctx.String(ValueFlag.Name)
+	deadline := ctx.Uint64(DeadlineFlag.Name)
+	relayer := ctx.String(RelayerFlag.Name)
+	file := ctx.String(FileFlag.Name)
+	path := ctx.String(PermitFlag.Name)
+	wait := ctx.Bool(WaitFlag.Name)
+	confirm := ctx.Uint64(ConfirmFlag.Name)
 
-	// get chain meta and connect
+	if path != "" && relayer == "" {
+		return errors.New("Input relayer to submit permit")
+	}
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
+	// get chain meta and connect it
 	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
 	if err != nil {
 		return err
 	}
 
-	c := chain.NewChain(meta.Id, meta.Currency, meta.Name)
-	if c == nil {
-		return errors.New("Connect chain failed")
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
 	}
 	defer c.DisConnect()
 
-	// query erc721 balance
-	erc721 := contract.NewContract(c, address, contract.ERC721_CONTRACT)
-	if erc721 == nil {
+	erc20 := contract.NewContract(c, address, contract.ERC20_CONTRACT)
+	if erc20 == nil {
 		return errors.New("Create erc20 contract failed")
 	}
 
-	balance, err := erc721.(*contract.ERC721Contract).Balance(account)
-	if err != nil {
-		return err
+	// read signed permit or sign it by from account
+	permit := &contract.ERC20Permit{}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		err = json.Unmarshal(data, permit)
+		if err != nil {
+			return err
+		}
+	} else {
+		owner, err := wallet.GetWallet(config.Config.Chain.From)
+		if err != nil {
+			return err
+		}
+
+		amount, err := erc20.(*contract.ERC20Contract).ParseAmount(cctx, value)
+		if err != nil {
+			return err
+		}
+
+		expiry := new(big.Int).SetUint64(uint64(time.Now().Unix()) + deadline)
+		permit, err = erc20.(*contract.ERC20Contract).SignPermit(cctx, to, amount, expiry, owner)
+		if err != nil {
+			return err
+		}
+
+		data, _ := json.MarshalIndent(permit, "", "  ")
+		if file != "" {
+			err = ioutil.WriteFile(file, data, 0644)
+			if err != nil {
+				return err
+			}
+		} else {
+			fmt.Println(string(data))
+		}
+	}
+
+	if relayer == "" {
+		return erc20.(*contract.ERC20Contract).VerifyPermit(cctx, permit)
+	}
+
+	// submit permit by relayer wallet
+	sender, err := wallet.GetWallet(relayer)
+	if err != nil {
+		return err
+	}
+
+	tx, err := erc20.(*contract.ERC20Contract).Permit(cctx, permit, sender)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Permit %s of %s to %s with transaction %s\n", permit.Value.String(), permit.Owner, permit.Spender, tx)
+	if wait {
+		return waitTransactions(cctx, c, []string{tx}, confirm)
+	}
+
+	return nil
+}
+
+func QueryERC721(ctx *cli.Context) error {
+	address := ctx.String(ContractFlag.Name)
+	account := ctx.String(AccountFlag.Name)
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
+	// get chain meta and connect
+	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
+	if err != nil {
+		return err
+	}
+
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
+	}
+	defer c.DisConnect()
+
+	// query erc721 balance
+	erc721 := contract.NewContract(c, address, contract.ERC721_CONTRACT)
+	if erc721 == nil {
+		return errors.New("Create erc20 contract failed")
+	}
+
+	balance, err := erc721.(*contract.ERC721Contract).Balance(cctx, account)
+	if err != nil {
+		return err
 	}
 
 	fmt.Fprintf(os.Stderr, "Balance: %d\n", balance)
@@ -490,6 +1052,13 @@ func TransferERC721(ctx *cli.Context) error {
 	to := ctx.String(ToFlag.Name)
 	value := ctx.String(ValueFlag.Name)
 	file := ctx.String(FileFlag.Name)
+	dryRun := ctx.Bool(DryRunFlag.Name)
+	safe := ctx.Bool(SafeFlag.Name)
+	data := common.FromHex(ctx.String(DataFlag.Name))
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
 
 	translist := make([]helper.TransferInfo, 0)
 	if to != "" && value != "" {
@@ -516,9 +1085,9 @@ func TransferERC721(ctx *cli.Context) error {
 		return err
 	}
 
-	c := chain.NewChain(meta.Id, meta.Currency, meta.Name)
-	if c == nil {
-		return errors.New("Connect chain failed")
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
 	}
 	defer c.DisConnect()
 
@@ -529,14 +1098,40 @@ func TransferERC721(ctx *cli.Context) error {
 	}
 
 	for _, info := range translist {
+		// stop the batch if interrupted
+		if cctx.Err() != nil {
+			return cctx.Err()
+		}
+
 		// get wallet for sign transaction
 		wallet, err := wallet.GetWallet(info.From)
 		if err != nil {
 			return err
 		}
 
-		tv, _ := strconv.ParseUint(info.Value, 10, 64)
-		tx, err := erc721.(*contract.ERC721Contract).Transfer(info.To, tv, wallet)
+		tv, err := parseTokenId(info.Value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Tranfer %s to %s failed with err: %v\n", info.Value, info.To, err)
+			continue
+		}
+
+		if dryRun {
+			sim, err := erc721.(*contract.ERC721Contract).DryRunTransfer(cctx, info.To, tv, wallet)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Simulate transfer %s to %s failed with err: %v\n", info.Value, info.To, err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Transfer %s to %s: %s\n", info.Value, info.To, sim)
+			}
+			continue
+		}
+
+		var tx string
+		if safe {
+			tx, err = erc721.(*contract.ERC721Contract).SafeTransfer(cctx, info.To, tv, data, wallet)
+		} else {
+			tx, err = erc721.(*contract.ERC721Contract).Transfer(cctx, info.To, tv, wallet)
+		}
+
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Tranfer %s to %s failed with err: %v\n", info.Value, info.To, err)
 		} else {
@@ -547,12 +1142,92 @@ func TransferERC721(ctx *cli.Context) error {
 	return nil
 }
 
+func InventoryERC721(ctx *cli.Context) error {
+	address := ctx.String(ContractFlag.Name)
+	start := ctx.Uint64(StartFlag.Name)
+	path := ctx.String(FileFlag.Name)
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
+	// get chain meta and connect it
+	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
+	if err != nil {
+		return err
+	}
+
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
+	}
+	defer c.DisConnect()
+
+	erc721 := contract.NewContract(c, address, contract.ERC721_CONTRACT)
+	if erc721 == nil {
+		return errors.New("Create erc721 contract failed")
+	}
+
+	// tokens of not enumerable contract are found by logs, scan from genesis is too heavy for most rpc servers
+	if !ctx.IsSet(StartFlag.Name) {
+		enumerable, err := erc721.(*contract.ERC721Contract).IsEnumerable(cctx)
+		if err != nil {
+			return err
+		}
+
+		if !enumerable {
+			return errors.New("Start block is required for not enumerable contract")
+		}
+	}
+
+	// tokens of all loaded accounts
+	accounts := make([]string, 0, len(wallet.AccountList))
+	for account := range wallet.AccountList {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	holdings, err := erc721.(*contract.ERC721Contract).Inventory(cctx, accounts, start)
+	if err != nil {
+		return err
+	}
+
+	for _, holding := range holdings {
+		tokenids := make([]string, 0, len(holding.TokenIds))
+		for _, tokenid := range holding.TokenIds {
+			tokenids = append(tokenids, tokenid.String())
+		}
+
+		fmt.Fprintf(os.Stderr, "%s: [%s]\n", holding.Account, strings.Join(tokenids, ","))
+	}
+
+	if path != "" {
+		return contract.SaveInventoryFile(erc721.Address(), holdings, path)
+	}
+
+	return nil
+}
+
 func ApproveERC721(ctx *cli.Context) error {
 	address := ctx.String(ContractFlag.Name)
 	to := ctx.String(ToFlag.Name)
 	value := ctx.String(ValueFlag.Name)
 	enable := ctx.Bool(EnableFlag.Name)
-	tokenId, _ := strconv.ParseUint(value, 10, 64)
+	all := ctx.IsSet(EnableFlag.Name)
+
+	// approve single token if enable is not set
+	var tokenId *big.Int
+	if !all {
+		var err error
+		tokenId, err = parseTokenId(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
 
 	// get wallet for sign transaction
 	wallet, err := wallet.GetWallet(config.Config.Chain.From)
@@ -566,9 +1241,9 @@ func ApproveERC721(ctx *cli.Context) error {
 		return err
 	}
 
-	c := chain.NewChain(meta.Id, meta.Currency, meta.Name)
-	if c == nil {
-		return errors.New("Connect chain failed")
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
 	}
 	defer c.DisConnect()
 
@@ -578,7 +1253,14 @@ func ApproveERC721(ctx *cli.Context) error {
 		return errors.New("Create erc20 contract failed")
 	}
 
-	tx, err := erc721.(*contract.ERC721Contract).Approve(to, tokenId, enable, wallet)
+	var tx string
+	if all {
+		value = "all"
+		tx, err = erc721.(*contract.ERC721Contract).ApproveAll(cctx, to, enable, wallet)
+	} else {
+		tx, err = erc721.(*contract.ERC721Contract).Approve(cctx, to, tokenId, wallet)
+	}
+
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Approve %s to %s failed with err: %v\n", value, to, err)
 	} else {
@@ -588,70 +1270,780 @@ func ApproveERC721(ctx *cli.Context) error {
 	return nil
 }
 
-func PropertyQuery(ctx *cli.Context) error {
+func QueryERC1155(ctx *cli.Context) error {
 	address := ctx.String(ContractFlag.Name)
-	value := ctx.String(ValueFlag.Name)
-	tokenId, _ := strconv.ParseUint(value, 10, 64)
+	account := helper.DefaultVlue(ctx.String(AccountFlag.Name), config.Config.Chain.From)
 
-	// get chain meta and connect it
+	ids, err := parseTokenIds(ctx.String(IdFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
+	// get chain meta and connect
 	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
 	if err != nil {
 		return err
 	}
 
-	c := chain.NewChain(meta.Id, meta.Currency, meta.Name)
-	if c == nil {
-		return errors.New("Connect chain failed")
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
 	}
 	defer c.DisConnect()
 
-	// transfer erc20 balance
-	erc721 := contract.NewContract(c, address, contract.ERC721_CONTRACT)
-	if erc721 == nil {
-		return errors.New("Create erc20 contract failed")
+	erc1155 := contract.NewContract(c, address, contract.ERC1155_CONTRACT)
+	if erc1155 == nil {
+		return errors.New("Create erc1155 contract failed")
+	}
+
+	// query all ids of account in one call
+	accounts := make([]string, len(ids))
+	for i := range ids {
+		accounts[i] = account
+	}
+
+	balances, err := erc1155.(*contract.ERC1155Contract).Balances(cctx, accounts, ids)
+	if err != nil {
+		return err
+	}
+
+	for i, id := range ids {
+		fmt.Fprintf(os.Stderr, "Balance of %s: %s\n", id.String(), balances[i].String())
+	}
+
+	return nil
+}
+
+func TransferERC1155(ctx *cli.Context) error {
+	address := ctx.String(ContractFlag.Name)
+	to := ctx.String(ToFlag.Name)
+	id := ctx.String(IdFlag.Name)
+	value := ctx.String(ValueFlag.Name)
+	file := ctx.String(FileFlag.Name)
+	data := common.FromHex(ctx.String(DataFlag.Name))
+	wait := ctx.Bool(WaitFlag.Name)
+	confirm := ctx.Uint64(ConfirmFlag.Name)
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
+	translist := make([]helper.TokenTransferInfo, 0)
+	if to != "" && id != "" && value != "" {
+		translist = append(translist, helper.TokenTransferInfo{
+			From:  config.Config.Chain.From,
+			To:    to,
+			Id:    id,
+			Value: value,
+		})
+	}
+
+	// read file transfer list
+	if file != "" {
+		list, err := helper.ReadTokenTransferFile(file)
+		if err != nil {
+			return err
+		}
+
+		translist = append(translist, list...)
+	}
+
+	// group transfers by sender and receiver in order
+	type batch struct {
+		from    string
+		to      string
+		ids     []*big.Int
+		amounts []*big.Int
+	}
+
+	batches := make([]*batch, 0)
+	for _, info := range translist {
+		tokenid, ok := new(big.Int).SetString(info.Id, 0)
+		if !ok {
+			return errors.New("Invalid token id " + info.Id)
+		}
+
+		amount, ok := new(big.Int).SetString(info.Value, 10)
+		if !ok {
+			return errors.New("Invalid amount " + info.Value)
+		}
+
+		var b *batch
+		for _, item := range batches {
+			if strings.EqualFold(item.from, info.From) && strings.EqualFold(item.to, info.To) {
+				b = item
+				break
+			}
+		}
+
+		if b == nil {
+			b = &batch{from: info.From, to: info.To}
+			batches = append(batches, b)
+		}
+
+		b.ids = append(b.ids, tokenid)
+		b.amounts = append(b.amounts, amount)
 	}
 
-	url, err := erc721.(*contract.ERC721Contract).TokenUrl(tokenId)
+	// get chain meta and connect it
+	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
 	if err != nil {
 		return err
 	}
 
-	url = strings.Replace(url, "ipfs://", "https://ipfs.io/ipfs/", 1)
-	result, err := utopia_network.HttpGet(url, nil, nil)
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
 	if err != nil {
 		return err
 	}
+	defer c.DisConnect()
+
+	erc1155 := contract.NewContract(c, address, contract.ERC1155_CONTRACT)
+	if erc1155 == nil {
+		return errors.New("Create erc1155 contract failed")
+	}
+
+	hashes := make([]string, 0, len(batches))
+	for _, b := range batches {
+		// stop the batch if interrupted
+		if cctx.Err() != nil {
+			return cctx.Err()
+		}
+
+		// get wallet for sign transaction
+		wallet, err := wallet.GetWallet(b.from)
+		if err != nil {
+			return err
+		}
+
+		var tx string
+		if len(b.ids) == 1 {
+			tx, err = erc1155.(*contract.ERC1155Contract).Transfer(cctx, b.to, b.ids[0], b.amounts[0], data, wallet)
+		} else {
+			tx, err = erc1155.(*contract.ERC1155Contract).BatchTransfer(cctx, b.to, b.ids, b.amounts, data, wallet)
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Tranfer %v of %v to %s failed with err: %v\n", b.amounts, b.ids, b.to, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Tranfer %v of %v to %s with transaction %s\n", b.amounts, b.ids, b.to, tx)
+			hashes = append(hashes, tx)
+		}
+	}
+
+	if wait {
+		return waitTransactions(cctx, c, hashes, confirm)
+	}
 
-	fmt.Fprintf(os.Stderr, "%s\n", string(result))
 	return nil
 }
 
-func EncodeABI(ctx *cli.Context) error {
-	method := ctx.String(FuncFlag.Name)
-	data := ctx.String(DataFlag.Name)
-	sign := ctx.Bool(SignFlag.Name)
+func ApproveERC1155(ctx *cli.Context) error {
+	address := ctx.String(ContractFlag.Name)
+	to := ctx.String(ToFlag.Name)
+	enable := ctx.Bool(EnableFlag.Name)
 
-	contract := contract.NewContract(nil, "", contract.COMMON_CRONTACT)
-	result, err := contract.EncodeABI(method, data, sign)
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
+	// get wallet for sign transaction
+	wallet, err := wallet.GetWallet(config.Config.Chain.From)
 	if err != nil {
 		return err
 	}
 
-	fmt.Fprintf(os.Stderr, "Result: %s\n", result)
+	// get chain meta and connect it
+	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
+	if err != nil {
+		return err
+	}
+
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
+	}
+	defer c.DisConnect()
+
+	erc1155 := contract.NewContract(c, address, contract.ERC1155_CONTRACT)
+	if erc1155 == nil {
+		return errors.New("Create erc1155 contract failed")
+	}
+
+	tx, err := erc1155.(*contract.ERC1155Contract).Approve(cctx, to, enable, wallet)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Approve all to %s failed with err: %v\n", to, err)
+	} else {
+		fmt.Fprintf(os.Stderr, "Approve all to %s with transaction %s\n", to, tx)
+	}
+
 	return nil
 }
 
-func DecodeABI(ctx *cli.Context) error {
-	method := ctx.String(FuncFlag.Name)
-	data := ctx.String(DataFlag.Name)
-	sign := ctx.Bool(SignFlag.Name)
+func UriERC1155(ctx *cli.Context) error {
+	address := ctx.String(ContractFlag.Name)
 
-	contract := contract.NewContract(nil, "", contract.COMMON_CRONTACT)
-	result, err := contract.DecodeABI(method, data, sign)
+	ids, err := parseTokenIds(ctx.String(IdFlag.Name))
 	if err != nil {
 		return err
 	}
 
-	fmt.Fprintf(os.Stderr, "Result: %s\n", result)
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
+	// get chain meta and connect
+	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
+	if err != nil {
+		return err
+	}
+
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
+	}
+	defer c.DisConnect()
+
+	erc1155 := contract.NewContract(c, address, contract.ERC1155_CONTRACT)
+	if erc1155 == nil {
+		return errors.New("Create erc1155 contract failed")
+	}
+
+	for _, id := range ids {
+		uri, err := erc1155.(*contract.ERC1155Contract).TokenUrl(cctx, id)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Uri of %s: %s\n", id.String(), uri)
+	}
+
+	return nil
+}
+
+// parse token id in decimal or hex with 0x
+func parseTokenId(input string) (*big.Int, error) {
+	id, ok := new(big.Int).SetString(strings.Trim(input, " "), 0)
+	if !ok || id.Sign() < 0 {
+		return nil, errors.New("Invalid token id " + input)
+	}
+
+	return id, nil
+}
+
+// parse token ids separated by comma, decimal or hex with 0x, the range like 1-100 includes both ends
+func parseTokenIds(input string) ([]*big.Int, error) {
+	if input == "" {
+		return nil, errors.New("Input token id")
+	}
+
+	ids := make([]*big.Int, 0)
+	for _, item := range strings.Split(input, ",") {
+		bounds := strings.SplitN(strings.Trim(item, " "), "-", 2)
+		first, err := parseTokenId(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			last, err = parseTokenId(bounds[1])
+			if err != nil || last.Cmp(first) < 0 {
+				return nil, errors.New("Invalid token id range " + item)
+			}
+		}
+
+		for id := first; id.Cmp(last) <= 0; id = new(big.Int).Add(id, common.Big1) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func PropertyQuery(ctx *cli.Context) error {
+	address := ctx.String(ContractFlag.Name)
+	value := ctx.String(ValueFlag.Name)
+
+	tokenId, err := parseTokenId(value)
+	if err != nil {
+		return err
+	}
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
+	// get chain meta and connect it
+	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
+	if err != nil {
+		return err
+	}
+
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
+	}
+	defer c.DisConnect()
+
+	// transfer erc20 balance
+	erc721 := contract.NewContract(c, address, contract.ERC721_CONTRACT)
+	if erc721 == nil {
+		return errors.New("Create erc20 contract failed")
+	}
+
+	url, err := erc721.(*contract.ERC721Contract).TokenUrl(cctx, tokenId)
+	if err != nil {
+		return err
+	}
+
+	resolver, db, err := openMetadataResolver(ctx.String(DatabaseFlag.Name))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tokenMeta, err := resolver.Resolve(cctx, url)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Name: %s\nDescription: %s\nImage: %s\n", tokenMeta.Name, tokenMeta.Description, tokenMeta.Image)
+	for _, attr := range tokenMeta.Attributes {
+		fmt.Fprintf(os.Stderr, "  %s: %s\n", attr.TraitType, attr.ValueText())
+	}
+
+	return nil
+}
+
+func TraitsERC721(ctx *cli.Context) error {
+	address := ctx.String(ContractFlag.Name)
+	path := ctx.String(FileFlag.Name)
+
+	ids, err := parseTokenIds(ctx.String(IdFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		return errors.New("Input excel file path")
+	}
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
+	// get chain meta and connect it
+	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
+	if err != nil {
+		return err
+	}
+
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
+	}
+	defer c.DisConnect()
+
+	erc721 := contract.NewContract(c, address, contract.ERC721_CONTRACT)
+	if erc721 == nil {
+		return errors.New("Create erc721 contract failed")
+	}
+
+	resolver, db, err := openMetadataResolver(ctx.String(DatabaseFlag.Name))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// skip the token failed to resolve
+	tokens := make([]*contract.TokenTraits, 0, len(ids))
+	for _, id := range ids {
+		if cctx.Err() != nil {
+			return cctx.Err()
+		}
+
+		url, err := erc721.(*contract.ERC721Contract).TokenUrl(cctx, id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Query uri of %s failed with err: %v\n", id.String(), err)
+			continue
+		}
+
+		tokenMeta, err := resolver.Resolve(cctx, url)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Resolve metadata of %s failed with err: %v\n", id.String(), err)
+			continue
+		}
+
+		tokens = append(tokens, &contract.TokenTraits{TokenId: id.String(), Meta: tokenMeta})
+	}
+
+	if len(tokens) == 0 {
+		return errors.New("No token metadata resolved")
+	}
+
+	counts := contract.RarityScores(tokens)
+	fmt.Fprintf(os.Stderr, "Resolve %d tokens with %d trait types\n", len(tokens), len(counts))
+	return contract.SaveTraitsFile(tokens, counts, path)
+}
+
+// open metadata resolver with database as cache and gateways in config
+func openMetadataResolver(path string) (*contract.MetadataResolver, *database.Database, error) {
+	db := database.NewDatabase(path)
+	err := db.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resolver, err := contract.NewMetadataResolver(db, config.Config.Metadata.IpfsGateways, config.Config.Metadata.ArweaveGateways)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	return resolver, db, nil
+}
+
+func QueryEvents(ctx *cli.Context) error {
+	address := ctx.String(ContractFlag.Name)
+	ctype := ctx.String(TypeFlag.Name)
+	abi := ctx.String(ABIFlag.Name)
+	name := ctx.String(EventFlag.Name)
+	start := ctx.Uint64(StartFlag.Name)
+	end := ctx.Uint64(EndFlag.Name)
+	path := ctx.String(FileFlag.Name)
+
+	// record abi methods for decoding call data if database given
+	if ctx.String(SelectorFlag.Name) != "" {
+		db, err := openSelectors(ctx.String(SelectorFlag.Name))
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+	}
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
+	// get chain meta and connect
+	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
+	if err != nil {
+		return err
+	}
+
+	chain, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
+	}
+	defer chain.DisConnect()
+
+	// create contract with bundled abi or abi file
+	var c contract.Contract
+	switch ctype {
+	case "erc20":
+		c = contract.NewContract(chain, address, contract.ERC20_CONTRACT)
+	case "erc721":
+		c = contract.NewContract(chain, address, contract.ERC721_CONTRACT)
+	case "erc1155":
+		c = contract.NewContract(chain, address, contract.ERC1155_CONTRACT)
+	case "":
+		c = contract.NewContract(chain, address, contract.COMMON_CRONTACT)
+		err = c.SetABI(abi)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("Not support contract type")
+	}
+
+	events, err := c.Events(cctx, name, start, end)
+	if err != nil {
+		return err
+	}
+
+	if path != "" {
+		err = contract.SaveEventFile(events, path)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Export %d events to %s\n", len(events), path)
+		return nil
+	}
+
+	for _, e := range events {
+		fmt.Println(e)
+	}
+
+	return nil
+}
+
+func EncodeABI(ctx *cli.Context) error {
+	method := ctx.String(FuncFlag.Name)
+	data := ctx.String(DataFlag.Name)
+	sign := ctx.Bool(SignFlag.Name)
+
+	contract := contract.NewContract(nil, "", contract.COMMON_CRONTACT)
+	result, err := contract.EncodeABI(method, data, sign)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Result: %s\n", result)
+	return nil
+}
+
+func DecodeABI(ctx *cli.Context) error {
+	method := ctx.String(FuncFlag.Name)
+	data := ctx.String(DataFlag.Name)
+	sign := ctx.Bool(SignFlag.Name)
+
+	// identify function by selector of call data
+	if method == "" {
+		db, err := openSelectors(ctx.String(DatabaseFlag.Name))
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		result, err := contract.Selectors.Decode(common.FromHex(data))
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Result: %s\n", result)
+		return nil
+	}
+
+	contract := contract.NewContract(nil, "", contract.COMMON_CRONTACT)
+	result, err := contract.DecodeABI(method, data, sign)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Result: %s\n", result)
+	return nil
+}
+
+func ImportSignatures(ctx *cli.Context) error {
+	path := ctx.String(SignatureFlag.Name)
+	abipath := ctx.String(ABIFlag.Name)
+
+	if path == "" && abipath == "" {
+		return errors.New("Input signature list file or abi file")
+	}
+
+	db, err := openSelectors(ctx.String(DatabaseFlag.Name))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	count := 0
+	if path != "" {
+		count, err = contract.Selectors.ImportFile(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	if abipath != "" {
+		data, err := ioutil.ReadFile(abipath)
+		if err != nil {
+			return err
+		}
+
+		parsed, err := abi.JSON(strings.NewReader(string(data)))
+		if err != nil {
+			return err
+		}
+
+		added, err := contract.Selectors.AddABI(&parsed)
+		if err != nil {
+			return err
+		}
+
+		count += added
+	}
+
+	fmt.Fprintf(os.Stderr, "Import %d new signatures\n", count)
+	return nil
+}
+
+// open selector database, the methods of abi set to contract are recorded
+func openSelectors(path string) (*database.Database, error) {
+	db := database.NewDatabase(path)
+	err := db.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	contract.Selectors, err = contract.NewSelectorDB(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// scan outstanding approvals of all loaded accounts and save them to excel file
+func ScanApprovals(ctx *cli.Context) error {
+	address := ctx.String(ContractFlag.Name)
+	start := ctx.Uint64(StartFlag.Name)
+	path := ctx.String(FileFlag.Name)
+
+	tokens := make([]string, 0)
+	for _, token := range strings.Split(address, ",") {
+		if token = strings.Trim(token, " "); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+
+	// scan logs of all contracts from genesis is too heavy for most rpc servers
+	if len(tokens) == 0 && !ctx.IsSet(StartFlag.Name) {
+		return errors.New("Start block is required when no contract given")
+	}
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
+	// get chain meta and connect it
+	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
+	if err != nil {
+		return err
+	}
+
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
+	}
+	defer c.DisConnect()
+
+	// approvals of all loaded accounts
+	accounts := make([]string, 0, len(wallet.AccountList))
+	for account := range wallet.AccountList {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	approvals, err := contract.ScanApprovals(cctx, c, accounts, tokens, start)
+	if err != nil {
+		return err
+	}
+
+	for _, approval := range approvals {
+		allowance := "all"
+		if approval.Allowance != nil {
+			allowance = approval.Allowance.String()
+		}
+
+		fmt.Fprintf(os.Stderr, "%s approve %s of %s to %s\n", approval.Owner, allowance, approval.Token, approval.Spender)
+	}
+	fmt.Fprintf(os.Stderr, "Found %d approvals of %d accounts\n", len(approvals), len(accounts))
+
+	if path != "" {
+		return contract.SaveApprovalFile(approvals, path)
+	}
+
+	return nil
+}
+
+func RevokeApprovals(ctx *cli.Context) error {
+	path := ctx.String(FileFlag.Name)
+	address := ctx.String(ContractFlag.Name)
+	spender := ctx.String(ToFlag.Name)
+	wait := ctx.Bool(WaitFlag.Name)
+	confirm := ctx.Uint64(ConfirmFlag.Name)
+
+	if path == "" {
+		return errors.New("Input excel file path")
+	}
+
+	approvals, err := contract.ReadApprovalFile(path)
+	if err != nil {
+		return err
+	}
+
+	// cancel all calls on interrupt
+	cctx, cancel := helper.SignalContext()
+	defer cancel()
+
+	// get chain meta and connect it
+	meta, err := chain.ChainMetaByName(config.Config.Chain.Network)
+	if err != nil {
+		return err
+	}
+
+	c, err := chain.NewChain(cctx, meta.Id, meta.Currency, meta.Name)
+	if err != nil {
+		return err
+	}
+	defer c.DisConnect()
+
+	hashes := make([]string, 0, len(approvals))
+	for _, approval := range approvals {
+		// stop the batch if interrupted
+		if cctx.Err() != nil {
+			return cctx.Err()
+		}
+
+		if address != "" && !strings.EqualFold(approval.Token, address) {
+			continue
+		}
+
+		if spender != "" && !strings.EqualFold(approval.Spender, spender) {
+			continue
+		}
+
+		// get wallet of owner for sign transaction
+		wallet, err := wallet.GetWallet(approval.Owner)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Revoke %s of %s to %s failed with err: %v\n", approval.Token, approval.Owner, approval.Spender, err)
+			continue
+		}
+
+		tx, err := contract.RevokeApproval(cctx, c, approval, wallet)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Revoke %s of %s to %s failed with err: %v\n", approval.Token, approval.Owner, approval.Spender, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Revoke %s of %s to %s with transaction %s\n", approval.Token, approval.Owner, approval.Spender, tx)
+			hashes = append(hashes, tx)
+		}
+	}
+
+	if wait {
+		return waitTransactions(cctx, c, hashes, confirm)
+	}
+
+	return nil
+}
+
+// wait transactions until final status and print the result
+func waitTransactions(cctx context.Context, c chain.Chain, hashes []string, confirm uint64) error {
+	ethchain, ok := c.(*chain.EthChain)
+	if !ok {
+		return errors.New("Wait only support evm chain")
+	}
+
+	tracker := chain.NewTracker(ethchain, confirm)
+	for _, hash := range hashes {
+		result, err := tracker.Wait(cctx, hash)
+		if cctx.Err() != nil {
+			return cctx.Err()
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Wait transaction %s failed with err: %v\n", hash, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s\n", result)
+		}
+	}
+
 	return nil
 }
@@ -23,7 +23,10 @@ func init() {
 		cmdList,
 		cmdERC20,
 		cmdERC721,
+		cmdERC1155,
+		cmdEvents,
 		cmdAbi,
+		cmdApproval,
 	}
 }
 
@@ -5,3 +5,9 @@ abigen --abi ./IERC20.abi --pkg token --type ERC20 --out ./erc20.go
 
 solc ./IERC721.sol --abi --optimize --overwrite --output-dir ./ >/dev/null
 abigen --abi ./IERC721.abi --pkg token --type ERC721 --out ./erc721.go
+
+solc ./IERC1155.sol --abi --optimize --overwrite --output-dir ./ >/dev/null
+abigen --abi ./IERC1155.abi --pkg token --type ERC1155 --out ./erc1155.go
+
+solc ./IERC20Permit.sol --abi --optimize --overwrite --output-dir ./ >/dev/null
+abigen --abi ./IERC20Permit.abi --pkg token --type ERC20Permit --out ./erc20permit.go
@@ -0,0 +1,138 @@
+package chain
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// contract backend for bind package, every call has timeout and failover of chain
+type ethBackend struct {
+	chain *EthChain
+}
+
+// get contract backend of chain
+func (chain *EthChain) Backend() bind.ContractBackend {
+	return &ethBackend{chain: chain}
+}
+
+func (b *ethBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	var code []byte
+	err := b.chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		code, err = client.CodeAt(ctx, contract, blockNumber)
+		return err
+	})
+
+	return code, err
+}
+
+func (b *ethBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	var result []byte
+	err := b.chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		result, err = client.CallContract(ctx, call, blockNumber)
+		return err
+	})
+
+	return result, err
+}
+
+func (b *ethBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	var header *types.Header
+	err := b.chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		header, err = client.HeaderByNumber(ctx, number)
+		return err
+	})
+
+	return header, err
+}
+
+func (b *ethBackend) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	var code []byte
+	err := b.chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		code, err = client.PendingCodeAt(ctx, account)
+		return err
+	})
+
+	return code, err
+}
+
+func (b *ethBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	var nonce uint64
+	err := b.chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		nonce, err = client.PendingNonceAt(ctx, account)
+		return err
+	})
+
+	return nonce, err
+}
+
+func (b *ethBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	var price *big.Int
+	err := b.chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		price, err = client.SuggestGasPrice(ctx)
+		return err
+	})
+
+	return price, err
+}
+
+func (b *ethBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	var tip *big.Int
+	err := b.chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		tip, err = client.SuggestGasTipCap(ctx)
+		return err
+	})
+
+	return tip, err
+}
+
+func (b *ethBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	var gas uint64
+	err := b.chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		gas, err = client.EstimateGas(ctx, call)
+		return err
+	})
+
+	return gas, err
+}
+
+// never resend transaction to other server
+func (b *ethBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	return b.chain.call(ctx, false, func(ctx context.Context, client *ethclient.Client) error {
+		return client.SendTransaction(ctx, tx)
+	})
+}
+
+func (b *ethBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	var logs []types.Log
+	err := b.chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		logs, err = client.FilterLogs(ctx, query)
+		return err
+	})
+
+	return logs, err
+}
+
+// subscription is long-lived, so only the caller context can cancel it
+func (b *ethBackend) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	client, _, err := b.chain.refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return ethclient.NewClient(client).SubscribeFilterLogs(ctx, query, ch)
+}
@@ -0,0 +1,222 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// max number of requests in one batch call
+const DEFAULT_BATCH_SIZE = 100
+
+// error of requests in batch call, the other requests are success
+type BatchError struct {
+	Errors []error // Error of each request, nil if success
+}
+
+func (e *BatchError) Error() string {
+	var first error
+	count := 0
+	for _, err := range e.Errors {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			count++
+		}
+	}
+
+	return fmt.Sprintf("%d of %d requests failed: %v", count, len(e.Errors), first)
+}
+
+// send requests in batches, the transport error fails all, the request error returns as BatchError
+func (chain *EthChain) batch(ctx context.Context, elems []rpc.BatchElem) error {
+	size := chain.BatchSize
+	if size <= 0 {
+		size = DEFAULT_BATCH_SIZE
+	}
+
+	for start := 0; start < len(elems); start += size {
+		end := start + size
+		if end > len(elems) {
+			end = len(elems)
+		}
+
+		// read only requests, so it is safe to retry on other server
+		err := chain.rawCall(ctx, true, func(ctx context.Context, client *rpc.Client) error {
+			for i := start; i < end; i++ {
+				elems[i].Error = nil
+			}
+
+			return client.BatchCallContext(ctx, elems[start:end])
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	failed := false
+	errs := make([]error, len(elems))
+	for i, elem := range elems {
+		if elem.Error != nil {
+			errs[i] = elem.Error
+			failed = true
+		}
+	}
+
+	if failed {
+		return &BatchError{Errors: errs}
+	}
+
+	return nil
+}
+
+// query latest balance of addresses, the balance is nil if failed
+func (chain *EthChain) BatchBalance(ctx context.Context, addresses []string) ([]*big.Int, error) {
+	results := make([]hexutil.Big, len(addresses))
+	elems := make([]rpc.BatchElem, len(addresses))
+	for i, address := range addresses {
+		elems[i] = rpc.BatchElem{
+			Method: "eth_getBalance",
+			Args:   []interface{}{common.HexToAddress(address), "latest"},
+			Result: &results[i],
+		}
+	}
+
+	err := chain.batch(ctx, elems)
+	if err != nil && !isBatchError(err) {
+		return nil, err
+	}
+
+	balances := make([]*big.Int, len(addresses))
+	for i := range results {
+		if elems[i].Error == nil {
+			balances[i] = results[i].ToInt()
+		}
+	}
+
+	return balances, err
+}
+
+// query pending nonce of addresses
+func (chain *EthChain) BatchNonce(ctx context.Context, addresses []string) ([]uint64, error) {
+	results := make([]hexutil.Uint64, len(addresses))
+	elems := make([]rpc.BatchElem, len(addresses))
+	for i, address := range addresses {
+		elems[i] = rpc.BatchElem{
+			Method: "eth_getTransactionCount",
+			Args:   []interface{}{common.HexToAddress(address), "pending"},
+			Result: &results[i],
+		}
+	}
+
+	err := chain.batch(ctx, elems)
+	if err != nil && !isBatchError(err) {
+		return nil, err
+	}
+
+	nonces := make([]uint64, len(addresses))
+	for i := range results {
+		nonces[i] = uint64(results[i])
+	}
+
+	return nonces, err
+}
+
+// query latest code of addresses
+func (chain *EthChain) BatchCode(ctx context.Context, addresses []string) ([][]byte, error) {
+	results := make([]hexutil.Bytes, len(addresses))
+	elems := make([]rpc.BatchElem, len(addresses))
+	for i, address := range addresses {
+		elems[i] = rpc.BatchElem{
+			Method: "eth_getCode",
+			Args:   []interface{}{common.HexToAddress(address), "latest"},
+			Result: &results[i],
+		}
+	}
+
+	err := chain.batch(ctx, elems)
+	if err != nil && !isBatchError(err) {
+		return nil, err
+	}
+
+	codes := make([][]byte, len(addresses))
+	for i := range results {
+		codes[i] = results[i]
+	}
+
+	return codes, err
+}
+
+// query receipts of transactions, the receipt not found is nil with ethereum.NotFound error
+func (chain *EthChain) BatchReceipt(ctx context.Context, hashes [][]byte) ([]*types.Receipt, error) {
+	receipts := make([]*types.Receipt, len(hashes))
+	elems := make([]rpc.BatchElem, len(hashes))
+	for i, hash := range hashes {
+		elems[i] = rpc.BatchElem{
+			Method: "eth_getTransactionReceipt",
+			Args:   []interface{}{common.BytesToHash(hash)},
+			Result: &receipts[i],
+		}
+	}
+
+	err := chain.batch(ctx, elems)
+	if err != nil && !isBatchError(err) {
+		return nil, err
+	}
+
+	return receipts, notFound(elems, func(i int) bool { return receipts[i] == nil }, err)
+}
+
+// query block headers by number, the header not found is nil with ethereum.NotFound error
+func (chain *EthChain) BatchHeader(ctx context.Context, numbers []uint64) ([]*types.Header, error) {
+	headers := make([]*types.Header, len(numbers))
+	elems := make([]rpc.BatchElem, len(numbers))
+	for i, number := range numbers {
+		elems[i] = rpc.BatchElem{
+			Method: "eth_getBlockByNumber",
+			Args:   []interface{}{hexutil.EncodeUint64(number), false},
+			Result: &headers[i],
+		}
+	}
+
+	err := chain.batch(ctx, elems)
+	if err != nil && !isBatchError(err) {
+		return nil, err
+	}
+
+	return headers, notFound(elems, func(i int) bool { return headers[i] == nil }, err)
+}
+
+func isBatchError(err error) bool {
+	_, ok := err.(*BatchError)
+	return ok
+}
+
+// mark null result of success request as not found
+func notFound(elems []rpc.BatchElem, empty func(i int) bool, err error) error {
+	errs := make([]error, len(elems))
+	failed := false
+	for i, elem := range elems {
+		errs[i] = elem.Error
+		if errs[i] == nil && empty(i) {
+			errs[i] = ethereum.NotFound
+		}
+
+		if errs[i] != nil {
+			failed = true
+		}
+	}
+
+	if failed {
+		return &BatchError{Errors: errs}
+	}
+
+	return err
+}
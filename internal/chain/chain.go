@@ -1,9 +1,12 @@
 package chain
 
 import (
+	"context"
 	"math/big"
+	"time"
 	"utopia/internal/wallet"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -20,27 +23,47 @@ const (
 	TLOS_MAINNET     = 40
 	DEV_NETWORK      = 12345
 	GANACHE_NETWORK  = 1337
+
+	// bitcoin has no chain id, use the default rpc port instead
+	BTC_MAINNET = 8332
+	BTC_REGTEST = 18443
 )
 
-// chain interface
+// default timeout for each rpc call
+const DEFAULT_CALL_TIMEOUT = 30 * time.Second
+
+// chain interface, all calls are canceled with the context
 type Chain interface {
-	Connect(rpc []string, checkid bool) error
+	Connect(ctx context.Context, rpc []string, checkid bool) error
 	DisConnect()
 
-	ChainId() (*big.Int, error)
-	GasPrice() (*big.Int, error)
+	ChainId(ctx context.Context) (*big.Int, error)
+	GasPrice(ctx context.Context) (*big.Int, error)
+	SuggestFeeRate(ctx context.Context) (*FeeRate, error)
+
+	BlockNumber(ctx context.Context) (uint64, error)
+	BlockByNumber(ctx context.Context, number uint64) (*Block, error)
+	BlockByHash(ctx context.Context, hash string) (*Block, error)
+
+	Transaction(ctx context.Context, hash string) (*Transaction, error)
+	Receipt(ctx context.Context, hash string) (*Receipt, error)
+
+	Balance(ctx context.Context, address string) (*big.Int, error)
+	Transfer(ctx context.Context, to string, value *big.Int, wallet wallet.Wallet) (string, error)
+	Nonce(ctx context.Context, address string) (uint64, error)
+	Code(ctx context.Context, address string) (string, error)
+}
 
-	BlockNumber() (uint64, error)
-	BlockByNumber(number uint64) (*types.Block, error)
-	BlockByHash(hash []byte) (*types.Block, error)
+// extension of evm chain with native types
+type EvmChain interface {
+	Chain
 
-	Transaction(hash []byte) (*types.Transaction, bool, error)
-	Receipt(hash []byte) (*types.Receipt, error)
-	SendTransaction(tx *types.Transaction, wallet wallet.Wallet) (string, error)
-	EstimateGas(tx *types.Transaction) (uint64, error)
+	EthBlockByNumber(ctx context.Context, number uint64) (*types.Block, error)
+	EthBlockByHash(ctx context.Context, hash []byte) (*types.Block, error)
+	EthTransaction(ctx context.Context, hash []byte) (*types.Transaction, bool, error)
+	EthReceipt(ctx context.Context, hash []byte) (*types.Receipt, error)
 
-	Balance(address string) (*big.Int, error)
-	Transfer(to string, value *big.Int, wallet wallet.Wallet) (string, error)
-	Nonce(address string) (uint64, error)
-	Code(address string) (string, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction, wallet wallet.Wallet) (string, error)
+	EstimateGas(ctx context.Context, tx *types.Transaction) (uint64, error)
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
 }
@@ -4,15 +4,30 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"time"
+)
+
+// type of chain
+const (
+	CHAIN_TYPE_EVM = "evm" // Ethereum compatible chain
+	CHAIN_TYPE_BTC = "btc" // Bitcoin core json-rpc
 )
 
 type ChainMeta struct {
-	Id        uint64   // Chain id
-	Name      string   // Chain name in full mode
-	Currency  string   // Currency name
-	IsTest    bool     // Is a test network
-	RpcServer []string // The rpc url list for call
-	Explorer  string   // The block chain explorer
+	Id               uint64   // Chain id
+	Type             string   // Chain type: evm or btc, empty means evm
+	Name             string   // Chain name in full mode
+	Currency         string   // Currency name
+	IsTest           bool     // Is a test network
+	RpcServer        []string // The rpc url list for call
+	Explorer         string   // The block chain explorer
+	Timeout          uint64   // Call timeout in millsecond, 0 means default
+	FeeMode          string   // Fee mode of transaction: auto, legacy or dynamic
+	BatchSize        int      // Max requests in one batch call, 0 means default
+	Multicall        string   // Address of multicall contract, empty if not deployed
+	MulticallBundled bool     // Multicall contract is the bundled MutiCall, otherwise Multicall3
+	Decimals         int      // Decimals of currency, 0 means 18
+	Rollup           string   // Rollup type for L1 data fee: opstack or arbitrum, empty if not rollup
 }
 
 var (
@@ -41,19 +56,36 @@ func ChainMetaByName(name string) (*ChainMeta, error) {
 	return &ChainList[index], nil
 }
 
-// add a new chain to meta list
-func AddChainMeta(id uint64, name string, currency string, isTest bool, server []string, explorer string) error {
-	_, ok := ChainIdMap[id]
-	if ok {
-		return errors.New("Chain id is exist")
+// get call timeout of chain
+func (meta *ChainMeta) CallTimeout() time.Duration {
+	if meta.Timeout == 0 {
+		return DEFAULT_CALL_TIMEOUT
 	}
 
-	_, ok = ChainNameMap[name]
-	if ok {
-		return errors.New("Chain name is exist")
+	return time.Duration(meta.Timeout) * time.Millisecond
+}
+
+// get type of chain
+func (meta *ChainMeta) ChainType() string {
+	if meta.Type == "" {
+		return CHAIN_TYPE_EVM
+	}
+
+	return meta.Type
+}
+
+// get decimals of chain currency
+func (meta *ChainMeta) Decimal() int {
+	if meta.Decimals == 0 {
+		return 18
 	}
 
-	ChainList = append(ChainList, ChainMeta{
+	return meta.Decimals
+}
+
+// add a new chain to meta list
+func AddChainMeta(id uint64, name string, currency string, isTest bool, server []string, explorer string) error {
+	return addChainMeta(ChainMeta{
 		Id:        id,
 		Name:      name,
 		Currency:  currency,
@@ -61,9 +93,22 @@ func AddChainMeta(id uint64, name string, currency string, isTest bool, server [
 		RpcServer: server,
 		Explorer:  explorer,
 	})
+}
+
+func addChainMeta(meta ChainMeta) error {
+	_, ok := ChainIdMap[meta.Id]
+	if ok {
+		return errors.New("Chain id is exist")
+	}
+
+	_, ok = ChainNameMap[meta.Name]
+	if ok {
+		return errors.New("Chain name is exist")
+	}
 
-	ChainIdMap[id] = len(ChainList) - 1
-	ChainNameMap[name] = len(ChainList) - 1
+	ChainList = append(ChainList, meta)
+	ChainIdMap[meta.Id] = len(ChainList) - 1
+	ChainNameMap[meta.Name] = len(ChainList) - 1
 
 	return nil
 }
@@ -82,7 +127,7 @@ func LoadChainList(path string) error {
 	}
 
 	for _, m := range meta {
-		AddChainMeta(m.Id, m.Name, m.Currency, m.IsTest, m.RpcServer, m.Explorer)
+		addChainMeta(m)
 	}
 
 	return nil
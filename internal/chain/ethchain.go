@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"math/big"
+	"sync"
+	"time"
 	"utopia/internal/wallet"
 
 	"github.com/ethereum/go-ethereum"
@@ -12,16 +14,25 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 type EthChain struct {
-	Id        uint64            // The id of chain
-	Currency  string            // Symbol of chain currency
-	Name      string            // Name of chain
-	Rpc       []string          // List of rpc server
-	Client    *ethclient.Client // Connection of chain
-	index     int               // Connect index of server list
-	connected bool              // Is connect to server
+	Id           uint64            // The id of chain
+	Currency     string            // Symbol of chain currency
+	Name         string            // Name of chain
+	Rpc          []string          // List of rpc server
+	Client       *ethclient.Client // Connection of chain
+	Timeout      time.Duration     // Call timeout of each request
+	Fee          FeeStrategy       // Strategy to suggest transaction fee
+	Estimator    FeeEstimator      // Estimator of total fee include L1 data fee
+	BatchSize    int               // Max requests in one batch call
+	PollInterval time.Duration     // Interval of polling subscription, 0 means default
+	rpcClient    *rpc.Client       // Raw rpc connection of chain
+	health       []*RpcHealth      // Health status of rpc server list
+	index        int               // Connect index of server list
+	connected    bool              // Is connect to server
+	lock         sync.Mutex        // Lock for connection and health status
 }
 
 func NewEthChain(id uint64, currency string, name string) Chain {
@@ -30,18 +41,29 @@ func NewEthChain(id uint64, currency string, name string) Chain {
 		return nil
 	}
 
+	estimator, err := NewFeeEstimator(meta.Rollup)
+	if err != nil {
+		return nil
+	}
+
 	return &EthChain{
 		Id:        id,
 		Currency:  currency,
 		Name:      name,
 		Rpc:       meta.RpcServer,
 		Client:    nil,
+		Timeout:   meta.CallTimeout(),
+		Fee:       NewFeeStrategy(meta.FeeMode),
+		Estimator: estimator,
+		BatchSize: meta.BatchSize,
+		rpcClient: nil,
+		health:    newHealthTable(meta.RpcServer),
 		index:     0,
 		connected: false,
 	}
 }
 
-func (chain *EthChain) Connect(server []string, checkid bool) error {
+func (chain *EthChain) Connect(ctx context.Context, server []string, checkid bool) error {
 	if len(server) == 0 {
 		server = chain.Rpc
 		if len(server) == 0 {
@@ -49,20 +71,23 @@ func (chain *EthChain) Connect(server []string, checkid bool) error {
 		}
 	}
 
-	// reset rpc server list and timeout
+	// reset rpc server list and health status
 	chain.DisConnect()
+	chain.lock.Lock()
 	chain.Rpc = server
+	chain.health = newHealthTable(server)
 	chain.index = 0
+	chain.lock.Unlock()
 
 	// refresh connect
-	err := chain.refresh()
+	_, _, err := chain.refresh(ctx)
 	if err != nil {
 		return err
 	}
 
 	// check chain id
 	if checkid {
-		id, err := chain.ChainId()
+		id, err := chain.ChainId(ctx)
 		if err != nil {
 			return err
 		}
@@ -76,278 +101,502 @@ func (chain *EthChain) Connect(server []string, checkid bool) error {
 }
 
 func (chain *EthChain) DisConnect() {
-	if chain.connected {
-		chain.Client.Close()
-	}
+	chain.lock.Lock()
+	defer chain.lock.Unlock()
 
-	chain.Client = nil
-	chain.connected = false
+	chain.close()
 }
 
-func (chain *EthChain) ChainId() (*big.Int, error) {
-	chain.refresh()
-	if !chain.connected {
-		return nil, errors.New("Chain not connected")
-	}
+func (chain *EthChain) ChainId(ctx context.Context) (*big.Int, error) {
+	var id *big.Int
 
 	// query chain id from server
-	id, err := chain.Client.ChainID(context.Background())
+	err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		id, err = client.ChainID(ctx)
+		return err
+	})
+
+	return id, err
+}
+
+func (chain *EthChain) GasPrice(ctx context.Context) (*big.Int, error) {
+	var gas *big.Int
+
+	// query gas price from chain
+	err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		gas, err = client.SuggestGasPrice(ctx)
+		return err
+	})
+
+	return gas, err
+}
+
+func (chain *EthChain) BlockNumber(ctx context.Context) (uint64, error) {
+	var number uint64
+
+	// query block number from chain
+	err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		number, err = client.BlockNumber(ctx)
+		return err
+	})
+
+	return number, err
+}
+
+func (chain *EthChain) BlockByNumber(ctx context.Context, number uint64) (*Block, error) {
+	block, err := chain.EthBlockByNumber(ctx, number)
 	if err != nil {
 		return nil, err
 	}
 
-	return id, nil
+	return newEthBlock(block), nil
 }
 
-func (chain *EthChain) GasPrice() (*big.Int, error) {
-	chain.refresh()
-	if !chain.connected {
-		return nil, errors.New("Chain not connected")
+func (chain *EthChain) BlockByHash(ctx context.Context, hash string) (*Block, error) {
+	block, err := chain.EthBlockByHash(ctx, common.FromHex(hash))
+	if err != nil {
+		return nil, err
 	}
 
-	// query gas price from chain
-	gas, err := chain.Client.SuggestGasPrice(context.Background())
+	return newEthBlock(block), nil
+}
+
+func (chain *EthChain) Transaction(ctx context.Context, hash string) (*Transaction, error) {
+	tx, pending, err := chain.EthTransaction(ctx, common.FromHex(hash))
 	if err != nil {
 		return nil, err
 	}
 
-	return gas, nil
+	return newEthTransaction(chain.Id, tx, pending), nil
 }
 
-func (chain *EthChain) BlockNumber() (uint64, error) {
-	chain.refresh()
-	if !chain.connected {
-		return 0, errors.New("Chain not connected")
+// receipt with the fee paid by effective gas price
+func (chain *EthChain) Receipt(ctx context.Context, hash string) (*Receipt, error) {
+	receipt, err := chain.EthReceipt(ctx, common.FromHex(hash))
+	if err != nil {
+		return nil, err
 	}
 
-	// query block number from chain
-	number, err := chain.Client.BlockNumber(context.Background())
+	tx, _, err := chain.EthTransaction(ctx, receipt.TxHash.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	price, err := chain.effectiveGasPrice(ctx, tx, receipt.BlockNumber)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	return number, nil
+	return newEthReceipt(receipt, price), nil
 }
 
-func (chain *EthChain) BlockByNumber(number uint64) (*types.Block, error) {
-	chain.refresh()
-	if !chain.connected {
-		return nil, errors.New("Chain not connected")
-	}
+func (chain *EthChain) EthBlockByNumber(ctx context.Context, number uint64) (*types.Block, error) {
+	var block *types.Block
 
 	// query block data from chain
-	return chain.Client.BlockByNumber(context.Background(), big.NewInt(int64(number)))
+	err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		block, err = client.BlockByNumber(ctx, big.NewInt(int64(number)))
+		return err
+	})
+
+	return block, err
 }
 
-func (chain *EthChain) BlockByHash(hash []byte) (*types.Block, error) {
-	chain.refresh()
-	if !chain.connected {
-		return nil, errors.New("Chain not connected")
-	}
+func (chain *EthChain) EthBlockByHash(ctx context.Context, hash []byte) (*types.Block, error) {
+	var block *types.Block
 
 	// query block data from chain
-	return chain.Client.BlockByHash(context.Background(), common.BytesToHash(hash))
+	err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		block, err = client.BlockByHash(ctx, common.BytesToHash(hash))
+		return err
+	})
+
+	return block, err
 }
 
-func (chain *EthChain) Transaction(hash []byte) (*types.Transaction, bool, error) {
-	chain.refresh()
-	if !chain.connected {
-		return nil, false, errors.New("Chain not connected")
-	}
+func (chain *EthChain) EthTransaction(ctx context.Context, hash []byte) (*types.Transaction, bool, error) {
+	var tx *types.Transaction
+	var pending bool
 
 	// query transaction from chain
-	return chain.Client.TransactionByHash(context.Background(), common.BytesToHash(hash))
+	err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		tx, pending, err = client.TransactionByHash(ctx, common.BytesToHash(hash))
+		return err
+	})
+
+	return tx, pending, err
 }
 
-func (chain *EthChain) Receipt(hash []byte) (*types.Receipt, error) {
-	chain.refresh()
-	if !chain.connected {
-		return nil, errors.New("Chain not connected")
-	}
+func (chain *EthChain) EthReceipt(ctx context.Context, hash []byte) (*types.Receipt, error) {
+	var receipt *types.Receipt
 
 	// query transaction receipt from chain
-	return chain.Client.TransactionReceipt(context.Background(), common.BytesToHash(hash))
+	err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		receipt, err = client.TransactionReceipt(ctx, common.BytesToHash(hash))
+		return err
+	})
+
+	return receipt, err
 }
 
-func (chain *EthChain) SendTransaction(tx *types.Transaction, wallet wallet.Wallet) (string, error) {
-	chain.refresh()
-	if !chain.connected {
-		return "", errors.New("Chain not connected")
+// gas price paid by packed transaction, dynamic fee is capped base fee plus tip
+func (chain *EthChain) effectiveGasPrice(ctx context.Context, tx *types.Transaction, number *big.Int) (*big.Int, error) {
+	if tx.Type() != types.DynamicFeeTxType {
+		return tx.GasPrice(), nil
 	}
 
-	// sign transaction and send
-	key, err := crypto.ToECDSA(common.FromHex(wallet.PrivateKey()))
+	var header *types.Header
+	err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		header, err = client.HeaderByNumber(ctx, number)
+		return err
+	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	signTx, err := types.SignTx(tx, types.NewLondonSigner(big.NewInt(int64(chain.Id))), key)
-	if err != nil {
-		return "", err
+	if header.BaseFee == nil {
+		return tx.GasPrice(), nil
+	}
+
+	price := new(big.Int).Add(header.BaseFee, tx.GasTipCap())
+	if price.Cmp(tx.GasFeeCap()) > 0 {
+		price = tx.GasFeeCap()
 	}
 
-	return signTx.Hash().Hex(), chain.Client.SendTransaction(context.Background(), signTx)
+	return price, nil
 }
 
-func (chain *EthChain) EstimateGas(tx *types.Transaction) (uint64, error) {
-	chain.refresh()
-	if !chain.connected {
-		return 0, errors.New("Chain not connected")
+func (chain *EthChain) SendTransaction(ctx context.Context, tx *types.Transaction, wallet wallet.Wallet) (string, error) {
+	signTx, err := chain.sendTransaction(ctx, tx, wallet)
+	if signTx == nil {
+		return "", err
 	}
 
+	return signTx.Hash().Hex(), err
+}
+
+func (chain *EthChain) EstimateGas(ctx context.Context, tx *types.Transaction) (uint64, error) {
+	var gas uint64
+
 	// estimate gas cost from chain
 	from, _ := types.Sender(types.NewLondonSigner(big.NewInt(int64(chain.Id))), tx)
-	return chain.Client.EstimateGas(context.Background(), ethereum.CallMsg{
-		From:       from,
-		To:         tx.To(),
-		Gas:        tx.Gas(),
-		GasPrice:   tx.GasPrice(),
-		GasFeeCap:  tx.GasFeeCap(),
-		GasTipCap:  tx.GasTipCap(),
-		Value:      tx.Value(),
-		Data:       tx.Data(),
-		AccessList: tx.AccessList(),
+	err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		gas, err = client.EstimateGas(ctx, ethereum.CallMsg{
+			From:       from,
+			To:         tx.To(),
+			Gas:        tx.Gas(),
+			GasPrice:   tx.GasPrice(),
+			GasFeeCap:  tx.GasFeeCap(),
+			GasTipCap:  tx.GasTipCap(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+		})
+		return err
 	})
+
+	return gas, err
 }
 
-func (chain *EthChain) Balance(address string) (*big.Int, error) {
-	chain.refresh()
-	if !chain.connected {
-		return nil, errors.New("Chain not connected")
-	}
+func (chain *EthChain) Balance(ctx context.Context, address string) (*big.Int, error) {
+	var balance *big.Int
 
 	// query acount balance from chain
-	balance, err := chain.Client.BalanceAt(context.Background(), common.HexToAddress(address), nil)
+	err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		balance, err = client.BalanceAt(ctx, common.HexToAddress(address), nil)
+		return err
+	})
+
 	return balance, err
 }
 
 // batch transfer value
-func (chain *EthChain) Transfer(to string, value *big.Int, wallet wallet.Wallet) (string, error) {
-	chain.refresh()
-	if !chain.connected {
-		return "", errors.New("Chain not connected")
-	}
-
+func (chain *EthChain) Transfer(ctx context.Context, to string, value *big.Int, wallet wallet.Wallet) (string, error) {
 	if to == wallet.Address() {
 		return "", errors.New("Can not transfer value to self")
 	}
 
-	// get sender nonce from chain
-	nonce, err := chain.Nonce(wallet.Address())
+	fee, err := chain.SuggestFee(ctx)
 	if err != nil {
 		return "", err
 	}
 
-	gasprice, err := chain.GasPrice()
+	// check balance is enough for value and max fee include L1 data fee
+	balance, err := chain.Balance(ctx, wallet.Address())
 	if err != nil {
 		return "", err
 	}
 
-	// check balance is enough
-	balance, err := chain.Balance(wallet.Address())
+	receiver := common.HexToAddress(to)
+	cost, err := chain.EstimateCost(ctx, chain.NewTransaction(0, &receiver, value, 21000, fee, nil))
 	if err != nil {
 		return "", err
 	}
 
-	if balance.Cmp(value) < 0 {
+	if balance.Cmp(new(big.Int).Add(value, cost.Total)) < 0 {
 		return "", errors.New("Not enough balance")
 	}
 
-	key, err := crypto.ToECDSA(common.FromHex(wallet.PrivateKey()))
+	// gen transaction with local nonce and send it
+	tx, err := chain.Transact(ctx, wallet.Address(), func(nonce uint64) (*types.Transaction, error) {
+		return chain.sendTransaction(ctx, chain.NewTransaction(nonce, &receiver, value, cost.GasLimit, fee, nil), wallet)
+	})
 	if err != nil {
 		return "", err
 	}
 
-	// gen transaction and sign it
-	tx := types.NewTransaction(nonce, common.HexToAddress(to), value, 21000, gasprice, nil)
-	signTx, err := types.SignTx(tx, types.NewLondonSigner(big.NewInt(int64(chain.Id))), key)
+	return tx.Hash().Hex(), nil
+}
+
+func (chain *EthChain) Nonce(ctx context.Context, address string) (uint64, error) {
+	var nonce uint64
+
+	// read account nonce from chain
+	err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		nonce, err = client.PendingNonceAt(ctx, common.HexToAddress(address))
+		return err
+	})
+
+	return nonce, err
+}
+
+func (chain *EthChain) Code(ctx context.Context, address string) (string, error) {
+	var code []byte
+
+	// read contract code from chain
+	err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		code, err = client.CodeAt(ctx, common.HexToAddress(address), nil)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
 
-	// send transaction
-	err = chain.Client.SendTransaction(context.Background(), signTx)
-	if err != nil {
-		return "", err
+	return "0x" + common.Bytes2Hex(code), nil
+}
+
+// get a copy of health status of all rpc server
+func (chain *EthChain) Health() []RpcHealth {
+	chain.lock.Lock()
+	defer chain.lock.Unlock()
+
+	result := make([]RpcHealth, 0, len(chain.health))
+	for _, h := range chain.health {
+		result = append(result, *h)
 	}
 
-	return signTx.Hash().Hex(), nil
+	return result
 }
 
-func (chain *EthChain) Nonce(address string) (uint64, error) {
-	chain.refresh()
-	if !chain.connected {
-		return 0, errors.New("Chain not connected")
+// probe every rpc server with block number call and update health status, the chain is not required to be connected
+func (chain *EthChain) Probe(ctx context.Context) []RpcHealth {
+	chain.lock.Lock()
+	if len(chain.health) != len(chain.Rpc) {
+		chain.health = newHealthTable(chain.Rpc)
 	}
+	table := chain.health
+	chain.lock.Unlock()
 
-	// read account nonce from chain
-	return chain.Client.PendingNonceAt(context.Background(), common.HexToAddress(address))
+	for _, h := range table {
+		if ctx.Err() != nil {
+			break
+		}
+
+		start := time.Now()
+		client, err := chain.dial(ctx, h.Url)
+		if err == nil {
+			cctx, cancel := chain.timeout(ctx)
+			_, err = ethclient.NewClient(client).BlockNumber(cctx)
+			cancel()
+			client.Close()
+		}
+
+		// rpc error means server is alive
+		if err != nil && !IsTransportError(err) {
+			err = nil
+		}
+
+		chain.lock.Lock()
+		h.record(time.Since(start), err)
+		chain.lock.Unlock()
+	}
+
+	return chain.Health()
 }
 
-func (chain *EthChain) Code(address string) (string, error) {
-	chain.refresh()
-	if !chain.connected {
-		return "", errors.New("Chain not connected")
+// call function on healthy server, retry on next server for transport error if enabled
+func (chain *EthChain) call(ctx context.Context, retry bool, fn func(ctx context.Context, client *ethclient.Client) error) error {
+	return chain.rawCall(ctx, retry, func(ctx context.Context, client *rpc.Client) error {
+		return fn(ctx, ethclient.NewClient(client))
+	})
+}
+
+// call function with raw rpc connection on healthy server
+func (chain *EthChain) rawCall(ctx context.Context, retry bool, fn func(ctx context.Context, client *rpc.Client) error) error {
+	var err error
+
+	attempts := 1
+	if retry && len(chain.Rpc) > 1 {
+		attempts = len(chain.Rpc)
 	}
 
-	// read contract code from chain
-	code, err := chain.Client.CodeAt(context.Background(), common.HexToAddress(address), nil)
+	for i := 0; i < attempts; i++ {
+		client, index, cerr := chain.refresh(ctx)
+		if cerr != nil {
+			if err != nil {
+				return err
+			}
+			return cerr
+		}
+
+		cctx, cancel := chain.timeout(ctx)
+		start := time.Now()
+		err = fn(cctx, client)
+		cancel()
+
+		// canceled by caller, not the fault of server
+		if ctx.Err() != nil {
+			return err
+		}
+
+		if !IsTransportError(err) {
+			chain.record(index, time.Since(start), nil)
+			return err
+		}
+
+		chain.record(index, time.Since(start), err)
+	}
+
+	return err
+}
+
+// sign transaction and send it, never resend transaction to other server
+func (chain *EthChain) sendTransaction(ctx context.Context, tx *types.Transaction, wallet wallet.Wallet) (*types.Transaction, error) {
+	key, err := crypto.ToECDSA(common.FromHex(wallet.PrivateKey()))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return "0x" + common.Bytes2Hex(code), nil
+	signTx, err := types.SignTx(tx, types.NewLondonSigner(big.NewInt(int64(chain.Id))), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return signTx, chain.call(ctx, false, func(ctx context.Context, client *ethclient.Client) error {
+		return client.SendTransaction(ctx, signTx)
+	})
+}
+
+// record call result of server, drop the connection if server is unhealthy
+func (chain *EthChain) record(index int, latency time.Duration, err error) {
+	chain.lock.Lock()
+	defer chain.lock.Unlock()
+
+	if index >= len(chain.health) {
+		return
+	}
+
+	chain.health[index].record(latency, err)
+	if err != nil && chain.index == index {
+		chain.close()
+	}
 }
 
-func (chain *EthChain) refresh() error {
+// get current connection, or connect the healthiest server
+func (chain *EthChain) refresh(ctx context.Context) (*rpc.Client, int, error) {
+	chain.lock.Lock()
+	defer chain.lock.Unlock()
+
 	if chain.connected {
-		return nil
+		return chain.rpcClient, chain.index, nil
+	}
+
+	if len(chain.health) != len(chain.Rpc) {
+		chain.health = newHealthTable(chain.Rpc)
 	}
 
-	// connect rpc server by index
-	for i := chain.index; i < len(chain.Rpc); i++ {
-		client, err := ethclient.Dial(chain.Rpc[i])
+	// connect rpc server by health order
+	for _, i := range orderByHealth(chain.health) {
+		client, err := chain.dial(ctx, chain.Rpc[i])
 		if err != nil {
+			chain.health[i].record(0, err)
 			continue
 		}
 
-		chain.Client = client
+		chain.rpcClient = client
+		chain.Client = ethclient.NewClient(client)
 		chain.index = i
 		chain.connected = true
 
-		return nil
+		return chain.rpcClient, chain.index, nil
 	}
 
-	for i := 0; i < len(chain.Rpc) && i < chain.index; i++ {
-		client, err := ethclient.Dial(chain.Rpc[i])
-		if err != nil {
-			continue
-		}
+	return nil, 0, errors.New("Can not connect any server")
+}
 
-		chain.Client = client
-		chain.index = i
-		chain.connected = true
+// close current connection, must hold the lock
+func (chain *EthChain) close() {
+	if chain.connected {
+		chain.Client.Close()
+	}
 
-		return nil
+	chain.Client = nil
+	chain.rpcClient = nil
+	chain.connected = false
+}
+
+// dial rpc server with chain timeout
+func (chain *EthChain) dial(ctx context.Context, url string) (*rpc.Client, error) {
+	ctx, cancel := chain.timeout(ctx)
+	defer cancel()
+
+	return rpc.DialContext(ctx, url)
+}
+
+// bound the context with the call timeout of chain
+func (chain *EthChain) timeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if chain.Timeout <= 0 {
+		return context.WithCancel(ctx)
 	}
 
-	return errors.New("Can not connect any server")
+	return context.WithTimeout(ctx, chain.Timeout)
 }
 
-func (c *EthChain) GenTransOpts(wallet wallet.Wallet, value *big.Int) (*bind.TransactOpts, error) {
+// the context of options is used by all calls of contract binding
+func (c *EthChain) GenTransOpts(ctx context.Context, wallet wallet.Wallet, value *big.Int) (*bind.TransactOpts, error) {
 	// set sign function
 	key, err := crypto.ToECDSA(common.FromHex(wallet.PrivateKey()))
 	if err != nil {
 		return nil, err
 	}
 	opts, _ := bind.NewKeyedTransactorWithChainID(key, new(big.Int).SetUint64(c.Id))
+	opts.Context = ctx
 
-	// set gasprice
-	opts.GasPrice, err = c.Client.SuggestGasPrice(context.Background())
+	// set gas fee by fee strategy of chain
+	fee, err := c.SuggestFee(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	if fee.IsDynamic() {
+		opts.GasFeeCap = fee.GasFeeCap
+		opts.GasTipCap = fee.GasTipCap
+	} else {
+		opts.GasPrice = fee.GasPrice
+	}
+
 	// set transfer value
 	opts.Value = value
 	return opts, nil
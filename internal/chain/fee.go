@@ -0,0 +1,186 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// fee mode of chain
+const (
+	FEE_MODE_AUTO    = "auto"    // Dynamic fee on london chain, otherwise legacy
+	FEE_MODE_LEGACY  = "legacy"  // Always legacy gas price
+	FEE_MODE_DYNAMIC = "dynamic" // Always dynamic fee
+)
+
+const (
+	FEE_HISTORY_BLOCKS     = 10 // Number of blocks to query fee history
+	FEE_HISTORY_PERCENTILE = 50 // Percentile of priority fee in each block
+)
+
+// fee of transaction, legacy fee only has gas price
+type Fee struct {
+	GasPrice  *big.Int // Gas price of legacy transaction
+	GasFeeCap *big.Int // Max fee per gas of dynamic fee transaction
+	GasTipCap *big.Int // Max priority fee per gas of dynamic fee transaction
+}
+
+// strategy to suggest transaction fee
+type FeeStrategy interface {
+	SuggestFee(ctx context.Context, chain *EthChain) (*Fee, error)
+}
+
+// legacy gas price from node
+type LegacyFee struct{}
+
+// dynamic fee with base fee and tip from fee history
+type DynamicFee struct {
+	Blocks     uint64  // Number of history blocks
+	Percentile float64 // Percentile of priority fee in block
+	Fallback   bool    // Fall back to legacy fee on pre-london chain
+}
+
+type feeHistory struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// create fee strategy by mode, empty mode means auto
+func NewFeeStrategy(mode string) FeeStrategy {
+	switch mode {
+	case FEE_MODE_LEGACY:
+		return &LegacyFee{}
+	case FEE_MODE_DYNAMIC:
+		return &DynamicFee{Blocks: FEE_HISTORY_BLOCKS, Percentile: FEE_HISTORY_PERCENTILE, Fallback: false}
+	default:
+		return &DynamicFee{Blocks: FEE_HISTORY_BLOCKS, Percentile: FEE_HISTORY_PERCENTILE, Fallback: true}
+	}
+}
+
+// is fee for dynamic fee transaction
+func (fee *Fee) IsDynamic() bool {
+	return fee.GasFeeCap != nil
+}
+
+// max price of each gas
+func (fee *Fee) MaxPrice() *big.Int {
+	if fee.IsDynamic() {
+		return fee.GasFeeCap
+	}
+
+	return fee.GasPrice
+}
+
+func (s *LegacyFee) SuggestFee(ctx context.Context, chain *EthChain) (*Fee, error) {
+	price, err := chain.GasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Fee{GasPrice: price}, nil
+}
+
+func (s *DynamicFee) SuggestFee(ctx context.Context, chain *EthChain) (*Fee, error) {
+	var history feeHistory
+
+	// query base fee and priority fee of recent blocks
+	err := chain.rawCall(ctx, true, func(ctx context.Context, client *rpc.Client) error {
+		return client.CallContext(ctx, &history, "eth_feeHistory", hexutil.Uint64(s.Blocks), "latest", []float64{s.Percentile})
+	})
+
+	// the last base fee is for next block, zero base fee means pre-london chain
+	var baseFee *big.Int
+	if err == nil && len(history.BaseFee) > 0 && history.BaseFee[len(history.BaseFee)-1] != nil {
+		baseFee = history.BaseFee[len(history.BaseFee)-1].ToInt()
+	}
+
+	if baseFee == nil || baseFee.Sign() == 0 {
+		if !s.Fallback {
+			if err != nil {
+				return nil, err
+			}
+			return nil, errors.New("Chain not support dynamic fee")
+		}
+
+		return (&LegacyFee{}).SuggestFee(ctx, chain)
+	}
+
+	// average tip of blocks, or suggested by node if no history
+	tip := new(big.Int)
+	count := int64(0)
+	for _, reward := range history.Reward {
+		if len(reward) > 0 && reward[0] != nil {
+			tip.Add(tip, reward[0].ToInt())
+			count++
+		}
+	}
+
+	if count > 0 {
+		tip.Div(tip, big.NewInt(count))
+	} else {
+		err = chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+			var err error
+			tip, err = client.SuggestGasTipCap(ctx)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// max fee is enough for base fee doubled in next blocks
+	feeCap := new(big.Int).Add(new(big.Int).Mul(baseFee, big.NewInt(2)), tip)
+	return &Fee{GasFeeCap: feeCap, GasTipCap: tip}, nil
+}
+
+// suggest transaction fee by fee strategy of chain
+func (chain *EthChain) SuggestFee(ctx context.Context) (*Fee, error) {
+	if chain.Fee == nil {
+		chain.Fee = NewFeeStrategy(FEE_MODE_AUTO)
+	}
+
+	return chain.Fee.SuggestFee(ctx, chain)
+}
+
+// suggested fee in neutral model, the price is the max price of each gas
+func (chain *EthChain) SuggestFeeRate(ctx context.Context) (*FeeRate, error) {
+	fee, err := chain.SuggestFee(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FeeRate{Price: fee.MaxPrice(), Unit: FEE_UNIT_WEI, Ext: fee}, nil
+}
+
+// build transaction with fee type, to is nil for contract creation
+func (chain *EthChain) NewTransaction(nonce uint64, to *common.Address, value *big.Int, gas uint64, fee *Fee, data []byte) *types.Transaction {
+	if fee.IsDynamic() {
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:   new(big.Int).SetUint64(chain.Id),
+			Nonce:     nonce,
+			GasTipCap: fee.GasTipCap,
+			GasFeeCap: fee.GasFeeCap,
+			Gas:       gas,
+			To:        to,
+			Value:     value,
+			Data:      data,
+		})
+	}
+
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: fee.GasPrice,
+		Gas:      gas,
+		To:       to,
+		Value:    value,
+		Data:     data,
+	})
+}
@@ -0,0 +1,123 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	HEALTH_RETRY_INTERVAL = 30 * time.Second // Wait time before retry an unhealthy server
+	HEALTH_LATENCY_WEIGHT = 0.2              // Weight of new sample in average latency
+)
+
+// health status of one rpc server
+type RpcHealth struct {
+	Url       string        // Url of rpc server
+	Healthy   bool          // Is the server healthy
+	Latency   time.Duration // Average latency of calls
+	Success   uint64        // Number of success calls
+	Failure   uint64        // Number of transport failed calls
+	LastError string        // The last transport error
+	LastCheck time.Time     // Time of the last call
+	retryAt   time.Time     // Time to retry when unhealthy
+}
+
+func newHealthTable(server []string) []*RpcHealth {
+	table := make([]*RpcHealth, 0, len(server))
+	for _, url := range server {
+		table = append(table, &RpcHealth{Url: url, Healthy: true})
+	}
+
+	return table
+}
+
+// rate of failed calls in all calls
+func (h *RpcHealth) ErrorRate() float64 {
+	total := h.Success + h.Failure
+	if total == 0 {
+		return 0
+	}
+
+	return float64(h.Failure) / float64(total)
+}
+
+// score of server, lower is better
+func (h *RpcHealth) Score() float64 {
+	return float64(h.Latency.Milliseconds()+1) * (1 + 10*h.ErrorRate())
+}
+
+// record the result of one call
+func (h *RpcHealth) record(latency time.Duration, err error) {
+	h.LastCheck = time.Now()
+
+	if err != nil {
+		h.Failure++
+		h.Healthy = false
+		h.LastError = err.Error()
+		h.retryAt = h.LastCheck.Add(HEALTH_RETRY_INTERVAL)
+		return
+	}
+
+	if h.Success == 0 {
+		h.Latency = latency
+	} else {
+		h.Latency = time.Duration(float64(h.Latency)*(1-HEALTH_LATENCY_WEIGHT) + float64(latency)*HEALTH_LATENCY_WEIGHT)
+	}
+
+	h.Success++
+	h.Healthy = true
+}
+
+// order server index by health score, unhealthy server is skipped until retry time
+func orderByHealth(table []*RpcHealth) []int {
+	now := time.Now()
+	order := make([]int, 0, len(table))
+	for i, h := range table {
+		if h.Healthy || now.After(h.retryAt) {
+			order = append(order, i)
+		}
+	}
+
+	// all servers are unhealthy, try them all in order
+	if len(order) == 0 {
+		for i := range table {
+			order = append(order, i)
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return table[order[i]].Score() < table[order[j]].Score()
+	})
+
+	return order
+}
+
+// check the error is caused by transport but not by server response
+func IsTransportError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return false
+	}
+
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
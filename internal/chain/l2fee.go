@@ -0,0 +1,199 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// rollup type of chain, the L1 data fee is estimated by rollup
+const (
+	ROLLUP_NONE     = ""         // Not a rollup, no L1 data fee
+	ROLLUP_OPSTACK  = "opstack"  // OP stack chain with GasPriceOracle predeploy
+	ROLLUP_ARBITRUM = "arbitrum" // Arbitrum chain with NodeInterface
+)
+
+const (
+	OP_GAS_PRICE_ORACLE       = "0x420000000000000000000000000000000000000F" // GasPriceOracle predeploy on OP stack
+	ARBITRUM_NODE_INTERFACE   = "0x00000000000000000000000000000000000000C8" // Virtual NodeInterface contract on Arbitrum
+	opGasPriceOracleABI       = `[{"inputs":[{"internalType":"bytes","name":"_data","type":"bytes"}],"name":"getL1Fee","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+	arbitrumNodeInterfaceABI  = `[{"inputs":[{"internalType":"address","name":"to","type":"address"},{"internalType":"bool","name":"contractCreation","type":"bool"},{"internalType":"bytes","name":"data","type":"bytes"}],"name":"gasEstimateL1Component","outputs":[{"internalType":"uint64","name":"gasEstimateForL1","type":"uint64"},{"internalType":"uint256","name":"baseFee","type":"uint256"},{"internalType":"uint256","name":"l1BaseFeeEstimate","type":"uint256"}],"stateMutability":"payable","type":"function"}]`
+	arbitrumL1ComponentMethod = "gasEstimateL1Component"
+)
+
+// expected cost of transaction with the max gas price
+type Cost struct {
+	GasLimit uint64   // Gas limit of transaction, include L1 gas on arbitrum
+	GasPrice *big.Int // Max price of each gas
+	L2Fee    *big.Int // Execution fee of gas limit without L1 gas
+	L1Fee    *big.Int // Data fee paid for L1, 0 if not rollup
+	Total    *big.Int // Total fee of transaction
+}
+
+// estimator of total fee of transaction on chain
+type FeeEstimator interface {
+	EstimateCost(ctx context.Context, chain *EthChain, tx *types.Transaction) (*Cost, error)
+}
+
+// fee of L1 chain, only execution fee
+type L1Estimator struct{}
+
+// L1 data fee from GasPriceOracle of OP stack
+type OpStackEstimator struct {
+	Oracle common.Address // Address of GasPriceOracle
+}
+
+// L1 gas from NodeInterface of arbitrum, the L1 fee is paid by L2 gas
+type ArbitrumEstimator struct {
+	NodeInterface common.Address // Address of NodeInterface
+}
+
+// create fee estimator by rollup type
+func NewFeeEstimator(rollup string) (FeeEstimator, error) {
+	switch rollup {
+	case ROLLUP_NONE:
+		return &L1Estimator{}, nil
+	case ROLLUP_OPSTACK:
+		return &OpStackEstimator{Oracle: common.HexToAddress(OP_GAS_PRICE_ORACLE)}, nil
+	case ROLLUP_ARBITRUM:
+		return &ArbitrumEstimator{NodeInterface: common.HexToAddress(ARBITRUM_NODE_INTERFACE)}, nil
+	default:
+		return nil, errors.New("Rollup type is not support")
+	}
+}
+
+func newCost(tx *types.Transaction) *Cost {
+	price := tx.GasPrice()
+	if tx.Type() == types.DynamicFeeTxType {
+		price = tx.GasFeeCap()
+	}
+
+	fee := new(big.Int).Mul(price, new(big.Int).SetUint64(tx.Gas()))
+	return &Cost{
+		GasLimit: tx.Gas(),
+		GasPrice: price,
+		L2Fee:    fee,
+		L1Fee:    big.NewInt(0),
+		Total:    new(big.Int).Set(fee),
+	}
+}
+
+func (e *L1Estimator) EstimateCost(ctx context.Context, chain *EthChain, tx *types.Transaction) (*Cost, error) {
+	return newCost(tx), nil
+}
+
+// the oracle charges the L1 fee by the serialized unsigned transaction
+func (e *OpStackEstimator) EstimateCost(ctx context.Context, chain *EthChain, tx *types.Transaction) (*Cost, error) {
+	parsed, err := abi.JSON(strings.NewReader(opGasPriceOracleABI))
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := parsed.Pack("getL1Fee", raw)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := chain.callContract(ctx, ethereum.CallMsg{To: &e.Oracle, Data: data})
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := parsed.Unpack("getL1Fee", output)
+	if err != nil {
+		return nil, err
+	}
+
+	cost := newCost(tx)
+	cost.L1Fee = values[0].(*big.Int)
+	cost.Total.Add(cost.Total, cost.L1Fee)
+	return cost, nil
+}
+
+// arbitrum charges the L1 fee as extra L2 gas, so the gas limit must cover it
+func (e *ArbitrumEstimator) EstimateCost(ctx context.Context, chain *EthChain, tx *types.Transaction) (*Cost, error) {
+	parsed, err := abi.JSON(strings.NewReader(arbitrumNodeInterfaceABI))
+	if err != nil {
+		return nil, err
+	}
+
+	to := common.Address{}
+	if tx.To() != nil {
+		to = *tx.To()
+	}
+
+	data, err := parsed.Pack(arbitrumL1ComponentMethod, to, tx.To() == nil, tx.Data())
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := chain.callContract(ctx, ethereum.CallMsg{To: &e.NodeInterface, Data: data})
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := parsed.Unpack(arbitrumL1ComponentMethod, output)
+	if err != nil {
+		return nil, err
+	}
+
+	l1Gas := values[0].(uint64)
+	cost := newCost(tx)
+	cost.GasLimit += l1Gas
+	cost.L1Fee = new(big.Int).Mul(cost.GasPrice, new(big.Int).SetUint64(l1Gas))
+	cost.Total.Add(cost.Total, cost.L1Fee)
+	return cost, nil
+}
+
+// estimate total cost of unsigned transaction by the estimator of chain
+func (chain *EthChain) EstimateCost(ctx context.Context, tx *types.Transaction) (*Cost, error) {
+	if chain.Estimator == nil {
+		chain.Estimator = &L1Estimator{}
+	}
+
+	return chain.Estimator.EstimateCost(ctx, chain, tx)
+}
+
+// cost of transaction with gas estimated by node, the estimated gas of arbitrum includes L1 gas already
+func (chain *EthChain) estimatedCost(ctx context.Context, tx *types.Transaction) (*Cost, error) {
+	cost, err := chain.EstimateCost(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := chain.Estimator.(*ArbitrumEstimator); ok {
+		l1Gas := cost.GasLimit - tx.Gas()
+		if l1Gas > tx.Gas() {
+			l1Gas = tx.Gas()
+		}
+
+		cost.GasLimit = tx.Gas()
+		cost.L2Fee = new(big.Int).Mul(cost.GasPrice, new(big.Int).SetUint64(tx.Gas()-l1Gas))
+		cost.Total = new(big.Int).Mul(cost.GasPrice, new(big.Int).SetUint64(tx.Gas()))
+	}
+
+	return cost, nil
+}
+
+func (chain *EthChain) callContract(ctx context.Context, msg ethereum.CallMsg) ([]byte, error) {
+	var output []byte
+	err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		output, err = client.CallContract(ctx, msg, nil)
+		return err
+	})
+
+	return output, err
+}
@@ -0,0 +1,99 @@
+package chain
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// number of blocks in each log query, split smaller if node limits the result
+const LOG_BLOCK_RANGE = 5000
+
+// error messages of nodes which limit the range or size of log query
+var logLimitErrors = []string{
+	"query returned more than",
+	"limit exceeded",
+	"block range",
+	"range too large",
+	"too many",
+	"response size",
+	"query timeout",
+}
+
+// query logs by address and topics, the block range is split if the node limits the result
+func (chain *EthChain) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	if query.BlockHash != nil {
+		return chain.filterLogs(ctx, query)
+	}
+
+	from := uint64(0)
+	if query.FromBlock != nil {
+		from = query.FromBlock.Uint64()
+	}
+
+	to := uint64(0)
+	if query.ToBlock != nil {
+		to = query.ToBlock.Uint64()
+	} else {
+		number, err := chain.BlockNumber(ctx)
+		if err != nil {
+			return nil, err
+		}
+		to = number
+	}
+
+	logs := make([]types.Log, 0)
+	size := uint64(LOG_BLOCK_RANGE)
+	for start := from; start <= to; {
+		end := start + size - 1
+		if end > to {
+			end = to
+		}
+
+		q := query
+		q.FromBlock = new(big.Int).SetUint64(start)
+		q.ToBlock = new(big.Int).SetUint64(end)
+
+		result, err := chain.filterLogs(ctx, q)
+		if err != nil {
+			// query half range again until single block
+			if isLogLimitError(err) && end > start {
+				size = (end - start + 1) / 2
+				continue
+			}
+
+			return nil, err
+		}
+
+		logs = append(logs, result...)
+		start = end + 1
+	}
+
+	return logs, nil
+}
+
+func (chain *EthChain) filterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	var logs []types.Log
+	err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		logs, err = client.FilterLogs(ctx, query)
+		return err
+	})
+
+	return logs, err
+}
+
+func isLogLimitError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, limit := range logLimitErrors {
+		if strings.Contains(msg, limit) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,29 +1,39 @@
 package chain
 
+import (
+	"context"
+	"fmt"
+)
+
+// chain creator by chain type of meta
 var (
-	ChainMap = map[uint64]func(uint64, string, string) Chain{
-		ETH_MAINNET:     NewEthChain,
-		BSC_MAINNET:     NewEthChain,
-		DEV_NETWORK:     NewEthChain,
-		GANACHE_NETWORK: NewEthChain,
+	ChainMap = map[string]func(uint64, string, string) Chain{
+		CHAIN_TYPE_EVM: NewEthChain,
+		CHAIN_TYPE_BTC: NewBtcChain,
 	}
 )
 
-func NewChain(id uint64, currency string, name string) Chain {
-	creator, ok := ChainMap[id]
+// create chain by the type in chain meta and connect it
+func NewChain(ctx context.Context, id uint64, currency string, name string) (Chain, error) {
+	meta, err := ChainMetaById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	creator, ok := ChainMap[meta.ChainType()]
 	if !ok {
-		return nil
+		return nil, fmt.Errorf("Chain type %s is not support", meta.ChainType())
 	}
 
 	chain := creator(id, currency, name)
 	if chain == nil {
-		return nil
+		return nil, fmt.Errorf("Create chain %d failed", id)
 	}
 
-	err := chain.Connect([]string{}, true)
+	err = chain.Connect(ctx, []string{}, true)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	return chain
+	return chain, nil
 }
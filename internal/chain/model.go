@@ -0,0 +1,145 @@
+package chain
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// unit of fee price
+const (
+	FEE_UNIT_WEI     = "wei"     // Gas price in wei on evm chain
+	FEE_UNIT_SAT_VB  = "sat/vB"  // Fee rate in satoshi per virtual byte on bitcoin
+	FEE_UNIT_UNKNOWN = "unknown" // Unknown unit
+)
+
+// block of any chain, the native block is in Ext
+type Block struct {
+	Number       uint64      // Block number or height
+	Hash         string      // Hash of block
+	ParentHash   string      // Hash of parent block
+	Time         uint64      // Timestamp of block in seconds
+	Transactions []string    // Hash of transactions in block
+	Ext          interface{} // Native block, *types.Block or *BtcBlock
+}
+
+// transaction of any chain, the native transaction is in Ext
+type Transaction struct {
+	Hash    string      // Hash of transaction
+	From    string      // Sender, empty if not single sender
+	To      string      // Receiver, empty on contract creation
+	Value   *big.Int    // Value transferred in min unit
+	Nonce   uint64      // Nonce of sender, 0 if chain has no nonce
+	Data    []byte      // Input data
+	Pending bool        // Is not packed in block
+	Ext     interface{} // Native transaction, *types.Transaction or *BtcTransaction
+}
+
+// packed result of transaction, the native receipt is in Ext
+type Receipt struct {
+	TxHash      string      // Hash of transaction
+	BlockHash   string      // Hash of block which transaction packed
+	BlockNumber uint64      // Number of block which transaction packed
+	Success     bool        // Is transaction executed successfully
+	GasUsed     uint64      // Gas used by transaction, 0 if chain has no gas
+	Fee         *big.Int    // Total fee paid, nil if unknown
+	Ext         interface{} // Native receipt, *types.Receipt or *BtcTransaction
+}
+
+// suggested fee price of chain, the native fee is in Ext
+type FeeRate struct {
+	Price *big.Int    // Price of each unit
+	Unit  string      // Unit of price
+	Ext   interface{} // Native fee, *Fee on evm chain
+}
+
+// native evm block, nil on other chain
+func (b *Block) Eth() *types.Block {
+	block, _ := b.Ext.(*types.Block)
+	return block
+}
+
+// native bitcoin block, nil on other chain
+func (b *Block) Btc() *BtcBlock {
+	block, _ := b.Ext.(*BtcBlock)
+	return block
+}
+
+// native evm transaction, nil on other chain
+func (tx *Transaction) Eth() *types.Transaction {
+	t, _ := tx.Ext.(*types.Transaction)
+	return t
+}
+
+// native bitcoin transaction, nil on other chain
+func (tx *Transaction) Btc() *BtcTransaction {
+	t, _ := tx.Ext.(*BtcTransaction)
+	return t
+}
+
+// native evm receipt, nil on other chain
+func (r *Receipt) Eth() *types.Receipt {
+	receipt, _ := r.Ext.(*types.Receipt)
+	return receipt
+}
+
+// native evm fee, nil on other chain
+func (f *FeeRate) Eth() *Fee {
+	fee, _ := f.Ext.(*Fee)
+	return fee
+}
+
+func newEthBlock(block *types.Block) *Block {
+	hashes := make([]string, 0, len(block.Transactions()))
+	for _, tx := range block.Transactions() {
+		hashes = append(hashes, tx.Hash().Hex())
+	}
+
+	return &Block{
+		Number:       block.NumberU64(),
+		Hash:         block.Hash().Hex(),
+		ParentHash:   block.ParentHash().Hex(),
+		Time:         block.Time(),
+		Transactions: hashes,
+		Ext:          block,
+	}
+}
+
+func newEthTransaction(id uint64, tx *types.Transaction, pending bool) *Transaction {
+	result := &Transaction{
+		Hash:    tx.Hash().Hex(),
+		Value:   tx.Value(),
+		Nonce:   tx.Nonce(),
+		Data:    tx.Data(),
+		Pending: pending,
+		Ext:     tx,
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(new(big.Int).SetUint64(id)), tx)
+	if err == nil {
+		result.From = from.Hex()
+	}
+
+	if tx.To() != nil {
+		result.To = tx.To().Hex()
+	}
+
+	return result
+}
+
+func newEthReceipt(receipt *types.Receipt, price *big.Int) *Receipt {
+	result := &Receipt{
+		TxHash:      receipt.TxHash.Hex(),
+		BlockHash:   receipt.BlockHash.Hex(),
+		BlockNumber: receipt.BlockNumber.Uint64(),
+		Success:     receipt.Status == types.ReceiptStatusSuccessful,
+		GasUsed:     receipt.GasUsed,
+		Ext:         receipt,
+	}
+
+	if price != nil {
+		result.Fee = new(big.Int).Mul(price, new(big.Int).SetUint64(receipt.GasUsed))
+	}
+
+	return result
+}
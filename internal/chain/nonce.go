@@ -0,0 +1,164 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// shared nonce manager for all chains
+var Nonces = NewNonceManager()
+
+// nonce manager allocates nonce locally for each chain and address
+type NonceManager struct {
+	lock   sync.Mutex
+	nonces map[string]*accountNonce
+}
+
+type accountNonce struct {
+	lock     sync.Mutex
+	next     uint64   // Next nonce to allocate
+	released []uint64 // Released nonces to reuse, sorted
+	synced   bool     // Is synced with chain
+}
+
+func NewNonceManager() *NonceManager {
+	return &NonceManager{
+		nonces: make(map[string]*accountNonce),
+	}
+}
+
+func (m *NonceManager) account(chain *EthChain, address string) *accountNonce {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	key := fmt.Sprintf("%d-%s", chain.Id, strings.ToLower(address))
+	n, ok := m.nonces[key]
+	if !ok {
+		n = &accountNonce{released: make([]uint64, 0)}
+		m.nonces[key] = n
+	}
+
+	return n
+}
+
+// allocate next nonce of address, sync with pending nonce on chain at first time
+func (m *NonceManager) Acquire(ctx context.Context, chain *EthChain, address string) (uint64, error) {
+	n := m.account(chain, address)
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if !n.synced {
+		err := n.sync(ctx, chain, address)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// fill the gap of released nonce first
+	if len(n.released) > 0 {
+		nonce := n.released[0]
+		n.released = n.released[1:]
+		return nonce, nil
+	}
+
+	nonce := n.next
+	n.next++
+	return nonce, nil
+}
+
+// give back the nonce which transaction not sent
+func (m *NonceManager) Release(chain *EthChain, address string, nonce uint64) {
+	n := m.account(chain, address)
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if nonce >= n.next {
+		return
+	}
+
+	for _, r := range n.released {
+		if r == nonce {
+			return
+		}
+	}
+
+	n.released = append(n.released, nonce)
+	sort.Slice(n.released, func(i, j int) bool { return n.released[i] < n.released[j] })
+}
+
+// drop local nonce, the next acquire will sync with chain
+func (m *NonceManager) Reset(chain *EthChain, address string) {
+	n := m.account(chain, address)
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.synced = false
+}
+
+// sync with pending nonce on chain, must hold the lock
+func (n *accountNonce) sync(ctx context.Context, chain *EthChain, address string) error {
+	pending, err := chain.Nonce(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	// keep local nonce if chain is behind, the server maybe lagged
+	if pending > n.next {
+		n.next = pending
+	}
+
+	released := make([]uint64, 0, len(n.released))
+	for _, r := range n.released {
+		if r >= pending && r < n.next {
+			released = append(released, r)
+		}
+	}
+
+	n.released = released
+	n.synced = true
+	return nil
+}
+
+// the nonce is used by other transaction
+func IsNonceUsed(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "nonce too low") || strings.Contains(msg, "replacement transaction underpriced")
+}
+
+// send transaction with nonce from nonce manager, retry once with synced nonce if the nonce was used
+func (chain *EthChain) Transact(ctx context.Context, from string, send func(nonce uint64) (*types.Transaction, error)) (*types.Transaction, error) {
+	for retry := 0; ; retry++ {
+		nonce, err := Nonces.Acquire(ctx, chain, from)
+		if err != nil {
+			return nil, err
+		}
+
+		tx, err := send(nonce)
+		if err == nil {
+			return tx, nil
+		}
+
+		if IsNonceUsed(err) {
+			Nonces.Reset(chain, from)
+			if retry == 0 {
+				continue
+			}
+		} else if IsTransportError(err) {
+			// transaction maybe received by server
+			Nonces.Reset(chain, from)
+		} else {
+			Nonces.Release(chain, from, nonce)
+		}
+
+		return tx, err
+	}
+}
@@ -0,0 +1,177 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+	"utopia/internal/wallet"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// minimum fee bump percent of replacement, same as default of geth txpool
+const DEFAULT_PRICE_BUMP = 10
+
+// replacer to speed up or cancel pending transaction
+type Replacer struct {
+	chain     *EthChain
+	PriceBump uint64 // Minimum fee bump percent required by node
+}
+
+func NewReplacer(chain *EthChain) *Replacer {
+	return &Replacer{
+		chain:     chain,
+		PriceBump: DEFAULT_PRICE_BUMP,
+	}
+}
+
+// get sender of transaction from signature
+func (r *Replacer) Sender(tx *types.Transaction) (common.Address, error) {
+	return types.Sender(types.LatestSignerForChainID(new(big.Int).SetUint64(r.chain.Id)), tx)
+}
+
+// resend transaction with same content and higher fee, fee is nil to use suggested fee
+func (r *Replacer) SpeedUp(ctx context.Context, tx *types.Transaction, fee *Fee, wallet wallet.Wallet) (*types.Transaction, error) {
+	fee, err := r.bump(ctx, tx, fee)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.send(ctx, tx, r.rebuild(tx, tx.To(), tx.Value(), tx.Gas(), tx.Data(), tx.AccessList(), fee), wallet)
+}
+
+// replace transaction with zero value self transfer, fee is nil to use suggested fee
+func (r *Replacer) Cancel(ctx context.Context, tx *types.Transaction, fee *Fee, wallet wallet.Wallet) (*types.Transaction, error) {
+	fee, err := r.bump(ctx, tx, fee)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := r.Sender(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.send(ctx, tx, r.rebuild(tx, &from, common.Big0, 21000, nil, nil, fee), wallet)
+}
+
+// list pending transactions of account in transaction pool, sort by nonce
+func (r *Replacer) Pending(ctx context.Context, address string) ([]*types.Transaction, error) {
+	var content map[string]map[string]*types.Transaction
+
+	err := r.chain.rawCall(ctx, true, func(ctx context.Context, client *rpc.Client) error {
+		return client.CallContext(ctx, &content, "txpool_contentFrom", common.HexToAddress(address))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*types.Transaction, 0)
+	for _, txs := range content {
+		for _, tx := range txs {
+			result = append(result, tx)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Nonce() < result[j].Nonce() })
+	return result, nil
+}
+
+// sign and send the replacement by sender of original transaction
+func (r *Replacer) send(ctx context.Context, tx *types.Transaction, replace *types.Transaction, wallet wallet.Wallet) (*types.Transaction, error) {
+	from, err := r.Sender(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(from.Hex(), wallet.Address()) {
+		return nil, errors.New("Wallet not match sender of transaction")
+	}
+
+	return r.chain.sendTransaction(ctx, replace, wallet)
+}
+
+// rebuild transaction with same type and nonce
+func (r *Replacer) rebuild(tx *types.Transaction, to *common.Address, value *big.Int, gas uint64, data []byte, access types.AccessList, fee *Fee) *types.Transaction {
+	switch tx.Type() {
+	case types.DynamicFeeTxType:
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  fee.GasTipCap,
+			GasFeeCap:  fee.GasFeeCap,
+			Gas:        gas,
+			To:         to,
+			Value:      value,
+			Data:       data,
+			AccessList: access,
+		})
+	case types.AccessListTxType:
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasPrice:   fee.GasPrice,
+			Gas:        gas,
+			To:         to,
+			Value:      value,
+			Data:       data,
+			AccessList: access,
+		})
+	default:
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: fee.GasPrice,
+			Gas:      gas,
+			To:       to,
+			Value:    value,
+			Data:     data,
+		})
+	}
+}
+
+// get fee for replacement with the type of transaction, and at least bumped by minimum percent
+func (r *Replacer) bump(ctx context.Context, tx *types.Transaction, fee *Fee) (*Fee, error) {
+	if fee == nil {
+		var err error
+		fee, err = r.chain.SuggestFee(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if tx.Type() == types.DynamicFeeTxType {
+		tip := fee.GasTipCap
+		if tip == nil {
+			tip = fee.GasPrice
+		}
+
+		tip = maxBig(tip, r.bumped(tx.GasTipCap()))
+
+		// tip can not be higher than fee cap
+		return &Fee{
+			GasFeeCap: maxBig(maxBig(fee.MaxPrice(), r.bumped(tx.GasFeeCap())), tip),
+			GasTipCap: tip,
+		}, nil
+	}
+
+	return &Fee{GasPrice: maxBig(fee.MaxPrice(), r.bumped(tx.GasPrice()))}, nil
+}
+
+// price increased by bump percent, round up
+func (r *Replacer) bumped(price *big.Int) *big.Int {
+	result := new(big.Int).Mul(price, new(big.Int).SetUint64(100+r.PriceBump))
+	result.Add(result, big.NewInt(99))
+	return result.Div(result, big.NewInt(100))
+}
+
+func maxBig(a *big.Int, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return new(big.Int).Set(a)
+	}
+
+	return new(big.Int).Set(b)
+}
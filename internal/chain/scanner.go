@@ -0,0 +1,397 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+	"utopia/internal/database"
+	"utopia/internal/logger"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	SCANNER_POLL_INTERVAL = 3 * time.Second // Interval to wait new block
+	SCANNER_HASH_DEPTH    = 64              // Number of recent block hashes kept for reorg detection
+)
+
+var scannerTables = []string{
+	"create table if not exists scanner_checkpoint(name text, chain integer, number integer, primary key(name, chain));",
+	"create table if not exists scanner_blocks(name text, chain integer, number integer, hash text, primary key(name, chain, number));",
+}
+
+// handler of scanned blocks, the data of rolled back blocks will be handled again
+type BlockHandler interface {
+	HandleBlock(ctx context.Context, block *types.Block) error
+	Rollback(ctx context.Context, number uint64) error // Revert data of blocks from number
+}
+
+// block scanner walks blocks forward and rolls back on reorg
+type Scanner struct {
+	chain         *EthChain
+	db            *database.Database
+	handlers      []BlockHandler
+	Name          string        // Name of scanner, key of checkpoint
+	Start         uint64        // First block to scan if no checkpoint
+	Confirmations uint64        // Number of blocks behind latest to scan
+	Depth         uint64        // Number of recent block hashes kept
+	Interval      time.Duration // Interval to wait new block
+}
+
+// create scanner with opened database, the checkpoint is saved with name
+func NewScanner(chain *EthChain, db *database.Database, name string) (*Scanner, error) {
+	for _, sql := range scannerTables {
+		_, err := db.ExecSql(sql)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Scanner{
+		chain:    chain,
+		db:       db,
+		handlers: make([]BlockHandler, 0),
+		Name:     name,
+		Depth:    SCANNER_HASH_DEPTH,
+		Interval: SCANNER_POLL_INTERVAL,
+	}, nil
+}
+
+func (s *Scanner) AddHandler(handler BlockHandler) {
+	s.handlers = append(s.handlers, handler)
+}
+
+// last scanned block number, false if never scanned
+func (s *Scanner) Checkpoint() (uint64, bool, error) {
+	rows, err := s.db.Query("select number from scanner_checkpoint where name = ? and chain = ?;", s.Name, int64(s.chain.Id))
+	if err != nil {
+		return 0, false, err
+	}
+
+	if len(rows) == 0 {
+		return 0, false, nil
+	}
+
+	return uint64(rows[0][0].(int)), true, nil
+}
+
+// scan blocks until context canceled, the transport error is retried
+func (s *Scanner) Run(ctx context.Context) error {
+	for {
+		scanned, err := s.Step(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			if !IsTransportError(err) {
+				return err
+			}
+
+			logger.Warn("Scanner %s failed with err: %v", s.Name, err)
+		}
+
+		if scanned && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.Interval):
+		}
+	}
+}
+
+// scan next block or roll back on reorg, false if no new block
+func (s *Scanner) Step(ctx context.Context) (bool, error) {
+	last, ok, err := s.Checkpoint()
+	if err != nil {
+		return false, err
+	}
+
+	next := s.Start
+	if ok {
+		next = last + 1
+	}
+
+	latest, err := s.chain.BlockNumber(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if next+s.Confirmations > latest {
+		return false, nil
+	}
+
+	block, err := s.chain.EthBlockByNumber(ctx, next)
+	if err != nil {
+		return false, err
+	}
+
+	// parent hash not match the saved one means reorg
+	if ok {
+		hash, found, err := s.blockHash(last)
+		if err != nil {
+			return false, err
+		}
+
+		if found && hash != block.ParentHash() {
+			return true, s.rollback(ctx, last)
+		}
+	}
+
+	// handler data and checkpoint are saved together, so a block is never handled twice after crash
+	return true, s.transaction(func() error {
+		for _, handler := range s.handlers {
+			err := handler.HandleBlock(ctx, block)
+			if err != nil {
+				return err
+			}
+		}
+
+		return s.saveBlock(next, block.Hash())
+	})
+}
+
+// find the common ancestor from number and roll back the blocks after it
+func (s *Scanner) rollback(ctx context.Context, number uint64) error {
+	for n := number; ; n-- {
+		saved, found, err := s.blockHash(n)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return errors.New("Reorg is deeper than saved blocks")
+		}
+
+		hash, err := s.headerHash(ctx, n)
+		if err != nil {
+			return err
+		}
+
+		if hash == saved {
+			logger.Info("Scanner %s reorg on chain %d, roll back from block %d", s.Name, s.chain.Id, n+1)
+			return s.revert(ctx, n)
+		}
+
+		if n == 0 {
+			return errors.New("Reorg is deeper than saved blocks")
+		}
+	}
+}
+
+// revert handlers and saved blocks after ancestor
+func (s *Scanner) revert(ctx context.Context, ancestor uint64) error {
+	return s.transaction(func() error {
+		for _, handler := range s.handlers {
+			err := handler.Rollback(ctx, ancestor+1)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := s.db.ExecSql("delete from scanner_blocks where name = ? and chain = ? and number > ?;", s.Name, int64(s.chain.Id), int64(ancestor))
+		if err != nil {
+			return err
+		}
+
+		_, err = s.db.ExecSql("update scanner_checkpoint set number = ? where name = ? and chain = ?;", int64(ancestor), s.Name, int64(s.chain.Id))
+		return err
+	})
+}
+
+// run fn in one database transaction, rolled back on error
+func (s *Scanner) transaction(fn func() error) error {
+	_, err := s.db.ExecSql("begin;")
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	if err != nil {
+		s.db.ExecSql("rollback;")
+		return err
+	}
+
+	_, err = s.db.ExecSql("commit;")
+	return err
+}
+
+// save block hash and checkpoint, prune the old hashes
+func (s *Scanner) saveBlock(number uint64, hash common.Hash) error {
+	id := int64(s.chain.Id)
+	_, err := s.db.ExecSql("insert or replace into scanner_blocks(name, chain, number, hash) values(?, ?, ?, ?);", s.Name, id, int64(number), hash.Hex())
+	if err != nil {
+		return err
+	}
+
+	if number >= s.Depth {
+		_, err = s.db.ExecSql("delete from scanner_blocks where name = ? and chain = ? and number <= ?;", s.Name, id, int64(number-s.Depth))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = s.db.ExecSql("insert or replace into scanner_checkpoint(name, chain, number) values(?, ?, ?);", s.Name, id, int64(number))
+	return err
+}
+
+// saved hash of block
+func (s *Scanner) blockHash(number uint64) (common.Hash, bool, error) {
+	rows, err := s.db.Query("select hash from scanner_blocks where name = ? and chain = ? and number = ?;", s.Name, int64(s.chain.Id), int64(number))
+	if err != nil {
+		return common.Hash{}, false, err
+	}
+
+	if len(rows) == 0 {
+		return common.Hash{}, false, nil
+	}
+
+	return common.HexToHash(rows[0][0].(string)), true, nil
+}
+
+// current hash of block on chain
+func (s *Scanner) headerHash(ctx context.Context, number uint64) (common.Hash, error) {
+	var header *types.Header
+	err := s.chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		header, err = client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+		return err
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return header.Hash(), nil
+}
+
+// native transfers from or to accounts, saved in database
+type TransferHandler struct {
+	db         *database.Database
+	chain      *EthChain
+	accounts   map[common.Address]bool
+	OnTransfer func(number uint64, tx *types.Transaction, from common.Address) // Called with each saved transfer
+}
+
+// create transfer handler for accounts
+func NewTransferHandler(chain *EthChain, db *database.Database, accounts []string) (*TransferHandler, error) {
+	_, err := db.ExecSql("create table if not exists scanner_transfers(chain integer, number integer, hash text, sender text, receiver text, value text, primary key(chain, hash));")
+	if err != nil {
+		return nil, err
+	}
+
+	h := &TransferHandler{
+		db:       db,
+		chain:    chain,
+		accounts: make(map[common.Address]bool),
+	}
+
+	for _, account := range accounts {
+		h.accounts[common.HexToAddress(account)] = true
+	}
+
+	return h, nil
+}
+
+func (h *TransferHandler) HandleBlock(ctx context.Context, block *types.Block) error {
+	signer := types.LatestSignerForChainID(new(big.Int).SetUint64(h.chain.Id))
+	for _, tx := range block.Transactions() {
+		if tx.To() == nil || tx.Value().Sign() == 0 {
+			continue
+		}
+
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return err
+		}
+
+		if !h.accounts[from] && !h.accounts[*tx.To()] {
+			continue
+		}
+
+		_, err = h.db.ExecSql("insert or replace into scanner_transfers(chain, number, hash, sender, receiver, value) values(?, ?, ?, ?, ?, ?);",
+			int64(h.chain.Id), int64(block.NumberU64()), tx.Hash().Hex(), from.Hex(), tx.To().Hex(), tx.Value().String())
+		if err != nil {
+			return err
+		}
+
+		if h.OnTransfer != nil {
+			h.OnTransfer(block.NumberU64(), tx, from)
+		}
+	}
+
+	return nil
+}
+
+func (h *TransferHandler) Rollback(ctx context.Context, number uint64) error {
+	_, err := h.db.ExecSql("delete from scanner_transfers where chain = ? and number >= ?;", int64(h.chain.Id), int64(number))
+	return err
+}
+
+// list saved transfers of account in block order
+func (h *TransferHandler) Transfers(account string) ([]string, error) {
+	address := common.HexToAddress(account).Hex()
+	rows, err := h.db.Query("select number, hash, sender, receiver, value from scanner_transfers where chain = ? and (sender = ? or receiver = ?) order by number;", int64(h.chain.Id), address, address)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, fmt.Sprintf("[%d][%s] %s -> %s value=%s", row[0], row[1], row[2], row[3], row[4]))
+	}
+
+	return result, nil
+}
+
+// contract logs in scanned blocks, handled by callbacks
+type LogHandler struct {
+	chain      *EthChain
+	Addresses  []common.Address                                                      // Contract addresses, empty for all
+	Topics     [][]common.Hash                                                       // Topics filter
+	OnLogs     func(ctx context.Context, block *types.Block, logs []types.Log) error // Called with logs of each block
+	OnRollback func(ctx context.Context, number uint64) error                        // Called when blocks from number rolled back
+}
+
+func NewLogHandler(chain *EthChain, addresses []string, topics [][]common.Hash) *LogHandler {
+	h := &LogHandler{
+		chain:     chain,
+		Addresses: make([]common.Address, 0, len(addresses)),
+		Topics:    topics,
+	}
+
+	for _, address := range addresses {
+		h.Addresses = append(h.Addresses, common.HexToAddress(address))
+	}
+
+	return h
+}
+
+func (h *LogHandler) HandleBlock(ctx context.Context, block *types.Block) error {
+	if h.OnLogs == nil {
+		return nil
+	}
+
+	hash := block.Hash()
+	logs, err := h.chain.FilterLogs(ctx, ethereum.FilterQuery{BlockHash: &hash, Addresses: h.Addresses, Topics: h.Topics})
+	if err != nil {
+		return err
+	}
+
+	return h.OnLogs(ctx, block, logs)
+}
+
+func (h *LogHandler) Rollback(ctx context.Context, number uint64) error {
+	if h.OnRollback == nil {
+		return nil
+	}
+
+	return h.OnRollback(ctx, number)
+}
@@ -0,0 +1,182 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"utopia/internal/helper"
+	"utopia/internal/wallet"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// expected balance change of account after transaction
+type BalanceChange struct {
+	Account  string   // Address of account
+	Asset    string   // Currency symbol or token contract address
+	Decimals int      // Decimals of asset
+	Before   *big.Int // Balance before transaction
+	After    *big.Int // Expected balance after transaction
+}
+
+// result of transaction simulated on pending block
+type Simulation struct {
+	Success  bool             // Transaction would be executed successfully
+	Reason   string           // Revert reason if failed
+	Gas      uint64           // Estimated gas limit
+	Cost     *Cost            // Total fee include L1 data fee
+	Output   []byte           // Return data of call
+	Currency string           // Symbol of chain currency
+	Decimals int              // Decimals of chain currency
+	Changes  []*BalanceChange // Expected balance changes
+}
+
+func (c *BalanceChange) String() string {
+	return fmt.Sprintf("%s %s: %s -> %s", c.Account, c.Asset, helper.FormatAmount(c.Before, c.Decimals), helper.FormatAmount(c.After, c.Decimals))
+}
+
+func (s *Simulation) String() string {
+	var builder strings.Builder
+	if s.Success {
+		builder.WriteString("Simulation success")
+	} else {
+		builder.WriteString(fmt.Sprintf("Simulation failed, reason=%s", s.Reason))
+	}
+
+	if s.Cost != nil {
+		builder.WriteString(fmt.Sprintf(", gas=%d, fee=%s %s, l1fee=%s %s", s.Gas, helper.FormatAmount(s.Cost.Total, s.Decimals), s.Currency,
+			helper.FormatAmount(s.Cost.L1Fee, s.Decimals), s.Currency))
+	}
+
+	for _, change := range s.Changes {
+		builder.WriteString("\n  ")
+		builder.WriteString(change.String())
+	}
+
+	return builder.String()
+}
+
+// add balance change of asset, the delta is added to after balance
+func (s *Simulation) AddChange(account string, asset string, decimals int, before *big.Int, delta *big.Int) {
+	for _, change := range s.Changes {
+		if change.Account == account && change.Asset == asset {
+			change.After.Add(change.After, delta)
+			return
+		}
+	}
+
+	s.Changes = append(s.Changes, &BalanceChange{
+		Account:  account,
+		Asset:    asset,
+		Decimals: decimals,
+		Before:   before,
+		After:    new(big.Int).Add(before, delta),
+	})
+}
+
+// fail the simulation if any balance after transaction is negative, call it again after adding changes
+func (s *Simulation) Check() {
+	for _, change := range s.Changes {
+		if s.Success && change.After.Sign() < 0 {
+			s.Success = false
+			s.Reason = fmt.Sprintf("insufficient %s balance of %s", change.Asset, change.Account)
+		}
+	}
+}
+
+// simulate message with eth_call and gas estimation on pending block, the native balance changes of sender and receiver are included
+func (chain *EthChain) Simulate(ctx context.Context, msg ethereum.CallMsg) (*Simulation, error) {
+	sim := &Simulation{
+		Success:  true,
+		Currency: chain.Currency,
+		Decimals: chain.decimals(),
+		Changes:  make([]*BalanceChange, 0),
+	}
+
+	// execution error is the result of simulation, only transport error is returned
+	err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		sim.Output, err = client.PendingCallContract(ctx, msg)
+		if err != nil && !IsTransportError(err) {
+			sim.Success = false
+			sim.Reason = RevertReason(err)
+			return nil
+		}
+
+		return err
+	})
+	if err != nil || !sim.Success {
+		return sim, err
+	}
+
+	err = chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		sim.Gas, err = client.EstimateGas(ctx, msg)
+		if err != nil && !IsTransportError(err) {
+			sim.Success = false
+			sim.Reason = RevertReason(err)
+			return nil
+		}
+
+		return err
+	})
+	if err != nil || !sim.Success {
+		return sim, err
+	}
+
+	fee, err := chain.SuggestFee(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	value := msg.Value
+	if value == nil {
+		value = big.NewInt(0)
+	}
+
+	sim.Cost, err = chain.estimatedCost(ctx, chain.NewTransaction(0, msg.To, value, sim.Gas, fee, msg.Data))
+	if err != nil {
+		return nil, err
+	}
+
+	// sender pays value and fee, receiver gets value
+	before, err := chain.Balance(ctx, msg.From.Hex())
+	if err != nil {
+		return nil, err
+	}
+
+	sim.AddChange(msg.From.Hex(), chain.Currency, sim.Decimals, before, new(big.Int).Neg(new(big.Int).Add(value, sim.Cost.Total)))
+	if msg.To != nil && value.Sign() > 0 && *msg.To != msg.From {
+		before, err = chain.Balance(ctx, msg.To.Hex())
+		if err != nil {
+			return nil, err
+		}
+
+		sim.AddChange(msg.To.Hex(), chain.Currency, sim.Decimals, before, value)
+	}
+
+	sim.Check()
+	return sim, nil
+}
+
+// simulate native transfer without sending it
+func (chain *EthChain) DryRunTransfer(ctx context.Context, to string, value *big.Int, wallet wallet.Wallet) (*Simulation, error) {
+	receiver := common.HexToAddress(to)
+	return chain.Simulate(ctx, ethereum.CallMsg{
+		From:  common.HexToAddress(wallet.Address()),
+		To:    &receiver,
+		Value: value,
+	})
+}
+
+func (chain *EthChain) decimals() int {
+	meta, err := ChainMetaById(chain.Id)
+	if err != nil {
+		return 18
+	}
+
+	return meta.Decimal()
+}
@@ -0,0 +1,379 @@
+package chain
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	SUBSCRIBE_POLL_INTERVAL  = 3 * time.Second // Interval of polling when no websocket server
+	SUBSCRIBE_RETRY_INTERVAL = 5 * time.Second // Interval to resubscribe after connection broken
+	SUBSCRIBE_LOG_BUFFER     = 1024            // Number of logs buffered during backfill
+)
+
+// long-lived subscription, resubscribe automatically until unsubscribed or the context canceled
+type Subscription struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	lock   sync.Mutex
+	err    error
+}
+
+// stop the subscription and wait it exit
+func (s *Subscription) Unsubscribe() {
+	s.cancel()
+	<-s.done
+}
+
+// closed when subscription exit
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// last error of subscription, the subscription is retrying if not done
+func (s *Subscription) Err() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.err
+}
+
+func (s *Subscription) setErr(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.err = err
+}
+
+// subscribe new block headers
+func (chain *EthChain) SubscribeHeads(ctx context.Context, ch chan<- *types.Header) *Subscription {
+	url := chain.wsUrl()
+	if url != "" {
+		return chain.subscribe(ctx, func(ctx context.Context) error {
+			return chain.wsSubscribe(ctx, url, ch, "newHeads")
+		})
+	}
+
+	var last uint64
+	return chain.subscribe(ctx, func(ctx context.Context) error {
+		return chain.pollBlocks(ctx, &last, func(ctx context.Context, from uint64, to uint64) error {
+			for number := from; number <= to; number++ {
+				var header *types.Header
+				err := chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+					var err error
+					header, err = client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+					return err
+				})
+				if err != nil {
+					return err
+				}
+
+				select {
+				case ch <- header:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+
+				last = number
+			}
+
+			return nil
+		})
+	})
+}
+
+// subscribe hash of new pending transactions
+func (chain *EthChain) SubscribePending(ctx context.Context, ch chan<- common.Hash) *Subscription {
+	url := chain.wsUrl()
+	if url != "" {
+		return chain.subscribe(ctx, func(ctx context.Context) error {
+			return chain.wsSubscribe(ctx, url, ch, "newPendingTransactions")
+		})
+	}
+
+	return chain.subscribe(ctx, func(ctx context.Context) error {
+		// filter is on single server, create it again if lost
+		var id string
+		err := chain.rawCall(ctx, true, func(ctx context.Context, client *rpc.Client) error {
+			return client.CallContext(ctx, &id, "eth_newPendingTransactionFilter")
+		})
+		if err != nil {
+			return err
+		}
+
+		for {
+			var hashes []common.Hash
+			err := chain.rawCall(ctx, false, func(ctx context.Context, client *rpc.Client) error {
+				return client.CallContext(ctx, &hashes, "eth_getFilterChanges", id)
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, hash := range hashes {
+				select {
+				case ch <- hash:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(chain.pollInterval()):
+			}
+		}
+	})
+}
+
+// subscribe logs matched the query, the block range of query is ignored
+func (chain *EthChain) SubscribeLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) *Subscription {
+	query.FromBlock = nil
+	query.ToBlock = nil
+	query.BlockHash = nil
+
+	// last block number synced, used to get the missed logs on reconnect
+	var last uint64
+
+	// logs sent from last block, the log may be both in backfill and subscription
+	seen := make(map[logKey]uint64)
+	send := func(ctx context.Context, logs []types.Log) error {
+		for _, log := range logs {
+			key := logKey{log.BlockHash, log.Index, log.Removed}
+			if _, ok := seen[key]; ok {
+				continue
+			}
+
+			select {
+			case ch <- log:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			seen[key] = log.BlockNumber
+			if log.BlockNumber > last {
+				last = log.BlockNumber
+			}
+		}
+
+		for key, number := range seen {
+			if number < last {
+				delete(seen, key)
+			}
+		}
+
+		return nil
+	}
+
+	url := chain.wsUrl()
+	if url != "" {
+		return chain.subscribe(ctx, func(ctx context.Context) error {
+			// start from latest block on first subscribe
+			if last == 0 {
+				number, err := chain.BlockNumber(ctx)
+				if err != nil {
+					return err
+				}
+
+				last = number
+			}
+
+			client, err := chain.dial(ctx, url)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+
+			// subscribe before backfill and buffer the logs, so the logs mined during backfill are not lost
+			logs := make(chan types.Log, SUBSCRIBE_LOG_BUFFER)
+			sub, err := client.EthSubscribe(ctx, logs, "logs", toFilterArg(query))
+			if err != nil {
+				return err
+			}
+			defer sub.Unsubscribe()
+
+			number, err := chain.BlockNumber(ctx)
+			if err != nil {
+				return err
+			}
+
+			// the last block is queried again since it may be partly sent
+			if number >= last {
+				q := query
+				q.FromBlock = new(big.Int).SetUint64(last)
+				q.ToBlock = new(big.Int).SetUint64(number)
+				backfill, err := chain.FilterLogs(ctx, q)
+				if err != nil {
+					return err
+				}
+
+				err = send(ctx, backfill)
+				if err != nil {
+					return err
+				}
+			}
+
+			if number > last {
+				last = number
+			}
+
+			for {
+				select {
+				case log := <-logs:
+					err := send(ctx, []types.Log{log})
+					if err != nil {
+						return err
+					}
+				case err := <-sub.Err():
+					return err
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		})
+	}
+
+	var polled uint64
+	return chain.subscribe(ctx, func(ctx context.Context) error {
+		return chain.pollBlocks(ctx, &polled, func(ctx context.Context, from uint64, to uint64) error {
+			q := query
+			q.FromBlock = new(big.Int).SetUint64(from)
+			q.ToBlock = new(big.Int).SetUint64(to)
+			logs, err := chain.FilterLogs(ctx, q)
+			if err != nil {
+				return err
+			}
+
+			err = send(ctx, logs)
+			if err != nil {
+				return err
+			}
+
+			polled = to
+			return nil
+		})
+	})
+}
+
+// identity of log, the removed log of reorg is different
+type logKey struct {
+	block   common.Hash
+	index   uint
+	removed bool
+}
+
+// run subscription in background, retry until context canceled
+func (chain *EthChain) subscribe(ctx context.Context, run func(ctx context.Context) error) *Subscription {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Subscription{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.done)
+		for {
+			err := run(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+
+			s.setErr(err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(SUBSCRIBE_RETRY_INTERVAL):
+			}
+		}
+	}()
+
+	return s
+}
+
+// subscribe on websocket server, return when the connection broken
+func (chain *EthChain) wsSubscribe(ctx context.Context, url string, ch interface{}, args ...interface{}) error {
+	client, err := chain.dial(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	sub, err := client.EthSubscribe(ctx, ch, args...)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-sub.Err():
+		return err
+	}
+}
+
+// poll new blocks and handle the range from last handled block, start from latest block if last is 0
+func (chain *EthChain) pollBlocks(ctx context.Context, last *uint64, handle func(ctx context.Context, from uint64, to uint64) error) error {
+	for {
+		number, err := chain.BlockNumber(ctx)
+		if err != nil {
+			return err
+		}
+
+		if *last == 0 && number > 0 {
+			*last = number - 1
+		}
+
+		if number > *last {
+			err = handle(ctx, *last+1, number)
+			if err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(chain.pollInterval()):
+		}
+	}
+}
+
+func (chain *EthChain) pollInterval() time.Duration {
+	if chain.PollInterval <= 0 {
+		return SUBSCRIBE_POLL_INTERVAL
+	}
+
+	return chain.PollInterval
+}
+
+// first websocket server in rpc list
+func (chain *EthChain) wsUrl() string {
+	for _, url := range chain.Rpc {
+		if strings.HasPrefix(url, "ws://") || strings.HasPrefix(url, "wss://") {
+			return url
+		}
+	}
+
+	return ""
+}
+
+// filter query in json-rpc format
+func toFilterArg(q ethereum.FilterQuery) interface{} {
+	arg := map[string]interface{}{
+		"address": q.Addresses,
+		"topics":  q.Topics,
+	}
+
+	return arg
+}
@@ -0,0 +1,288 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+	"utopia/internal/logger"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// status of transaction
+const (
+	TX_STATUS_PENDING  = 0
+	TX_STATUS_SUCCESS  = 1
+	TX_STATUS_REVERTED = 2
+	TX_STATUS_DROPPED  = 3
+)
+
+const (
+	TRACKER_POLL_INTERVAL = 3 * time.Second // Interval to poll receipt
+	TRACKER_DROP_TIMEOUT  = 5 * time.Minute // Transaction not found in time is dropped
+)
+
+// final result of transaction
+type TxResult struct {
+	Hash          string         // Transaction hash
+	Status        int            // Status of transaction
+	BlockNumber   uint64         // Block number which transaction packed
+	GasUsed       uint64         // Gas used by transaction
+	GasPrice      *big.Int       // Effective gas price
+	Fee           *big.Int       // Total fee paid by sender
+	Confirmations uint64         // Number of confirmed blocks
+	Reason        string         // Revert reason or drop reason
+	Receipt       *types.Receipt // Receipt of transaction
+}
+
+// tracker to wait transaction receipt with confirmations
+type Tracker struct {
+	chain         *EthChain
+	Confirmations uint64        // Number of blocks to confirm
+	Interval      time.Duration // Interval of polling
+	DropTimeout   time.Duration // Mark dropped if transaction not found in time
+}
+
+func NewTracker(chain *EthChain, confirmations uint64) *Tracker {
+	if confirmations == 0 {
+		confirmations = 1
+	}
+
+	return &Tracker{
+		chain:         chain,
+		Confirmations: confirmations,
+		Interval:      TRACKER_POLL_INTERVAL,
+		DropTimeout:   TRACKER_DROP_TIMEOUT,
+	}
+}
+
+func (r *TxResult) StatusText() string {
+	switch r.Status {
+	case TX_STATUS_SUCCESS:
+		return "success"
+	case TX_STATUS_REVERTED:
+		return "reverted"
+	case TX_STATUS_DROPPED:
+		return "dropped"
+	default:
+		return "pending"
+	}
+}
+
+func (r *TxResult) String() string {
+	result := fmt.Sprintf("Transaction %s status=%s", r.Hash, r.StatusText())
+	if r.Receipt != nil {
+		result += fmt.Sprintf(", block=%d, confirmations=%d, gasused=%d", r.BlockNumber, r.Confirmations, r.GasUsed)
+	}
+
+	if r.Fee != nil {
+		result += fmt.Sprintf(", fee=%s", r.Fee.String())
+	}
+
+	if r.Reason != "" {
+		result += fmt.Sprintf(", reason=%s", r.Reason)
+	}
+
+	return result
+}
+
+// wait transaction until confirmed, reverted or dropped
+func (t *Tracker) Wait(ctx context.Context, hash string) (*TxResult, error) {
+	result := &TxResult{Hash: hash, Status: TX_STATUS_PENDING}
+	lastSeen := time.Now()
+
+	for {
+		done, err := t.check(ctx, result, &lastSeen)
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		// retry on transport error until context done
+		if err != nil {
+			if !IsTransportError(err) {
+				return result, err
+			}
+
+			logger.Warn("Track transaction %s failed with err: %v", result.Hash, err)
+		} else if done {
+			return result, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(t.Interval):
+		}
+	}
+}
+
+// check transaction status once, return true if final status
+func (t *Tracker) check(ctx context.Context, result *TxResult, lastSeen *time.Time) (bool, error) {
+	hash := common.HexToHash(result.Hash)
+
+	receipt, err := t.chain.EthReceipt(ctx, hash.Bytes())
+	if err != nil && !errors.Is(err, ethereum.NotFound) {
+		return false, err
+	}
+
+	// not packed, check transaction is still in pool
+	if receipt == nil {
+		tx, _, err := t.chain.EthTransaction(ctx, hash.Bytes())
+		if errors.Is(err, ethereum.NotFound) {
+			if time.Since(*lastSeen) > t.DropTimeout {
+				result.Status = TX_STATUS_DROPPED
+				result.Reason = "not found in transaction pool"
+				return true, nil
+			}
+
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+
+		*lastSeen = time.Now()
+		return t.replaced(ctx, tx, result)
+	}
+
+	// wait enough confirmations
+	number, err := t.chain.BlockNumber(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	result.Receipt = receipt
+	result.BlockNumber = receipt.BlockNumber.Uint64()
+	result.GasUsed = receipt.GasUsed
+	result.Confirmations = 0
+	if number >= result.BlockNumber {
+		result.Confirmations = number - result.BlockNumber + 1
+	}
+
+	if result.Confirmations < t.Confirmations {
+		return false, nil
+	}
+
+	tx, _, err := t.chain.EthTransaction(ctx, hash.Bytes())
+	if err != nil {
+		return false, err
+	}
+
+	err = t.fee(ctx, tx, result)
+	if err != nil {
+		return false, err
+	}
+
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		result.Status = TX_STATUS_SUCCESS
+		return true, nil
+	}
+
+	result.Status = TX_STATUS_REVERTED
+	result.Reason = t.reason(ctx, tx, receipt)
+	return true, nil
+}
+
+// pending transaction is dropped if the nonce was used by other transaction
+func (t *Tracker) replaced(ctx context.Context, tx *types.Transaction, result *TxResult) (bool, error) {
+	from, err := types.Sender(types.LatestSignerForChainID(new(big.Int).SetUint64(t.chain.Id)), tx)
+	if err != nil {
+		return false, err
+	}
+
+	var nonce uint64
+	err = t.chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		nonce, err = client.NonceAt(ctx, from, nil)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if nonce > tx.Nonce() {
+		// receipt maybe packed after the nonce query
+		receipt, err := t.chain.EthReceipt(ctx, tx.Hash().Bytes())
+		if err == nil && receipt != nil {
+			return false, nil
+		}
+
+		result.Status = TX_STATUS_DROPPED
+		result.Reason = "nonce used by other transaction"
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// calculate effective gas price and fee of packed transaction
+func (t *Tracker) fee(ctx context.Context, tx *types.Transaction, result *TxResult) error {
+	price, err := t.chain.effectiveGasPrice(ctx, tx, result.Receipt.BlockNumber)
+	if err != nil {
+		return err
+	}
+
+	result.GasPrice = price
+	result.Fee = new(big.Int).Mul(price, new(big.Int).SetUint64(result.GasUsed))
+	return nil
+}
+
+// replay reverted transaction on parent block to get revert reason
+func (t *Tracker) reason(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) string {
+	from, err := types.Sender(types.LatestSignerForChainID(new(big.Int).SetUint64(t.chain.Id)), tx)
+	if err != nil {
+		return "unknown"
+	}
+
+	msg := ethereum.CallMsg{
+		From:       from,
+		To:         tx.To(),
+		Gas:        tx.Gas(),
+		GasPrice:   tx.GasPrice(),
+		GasFeeCap:  tx.GasFeeCap(),
+		GasTipCap:  tx.GasTipCap(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	}
+
+	// dynamic fee and gas price can not be set together
+	if tx.Type() == types.DynamicFeeTxType {
+		msg.GasPrice = nil
+	} else {
+		msg.GasFeeCap = nil
+		msg.GasTipCap = nil
+	}
+
+	parent := new(big.Int).Sub(receipt.BlockNumber, common.Big1)
+	err = t.chain.call(ctx, true, func(ctx context.Context, client *ethclient.Client) error {
+		_, err := client.CallContract(ctx, msg, parent)
+		return err
+	})
+	if err == nil {
+		return "unknown"
+	}
+
+	return RevertReason(err)
+}
+
+// decode revert reason from call error
+func RevertReason(err error) string {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if data, ok := dataErr.ErrorData().(string); ok {
+			reason, uerr := abi.UnpackRevert(common.FromHex(data))
+			if uerr == nil {
+				return reason
+			}
+		}
+	}
+
+	return err.Error()
+}
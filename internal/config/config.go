@@ -30,9 +30,16 @@ type ChainConfig struct {
 	From            string `json:"from"`
 }
 
+// gateways tried in order to fetch nft metadata
+type MetadataConfig struct {
+	IpfsGateways    []string `json:"ipfs"`
+	ArweaveGateways []string `json:"arweave"`
+}
+
 type Configs struct {
-	Server ServiceConfig `json:"service"`
-	Chain  ChainConfig   `json:"chain"`
+	Server   ServiceConfig  `json:"service"`
+	Chain    ChainConfig    `json:"chain"`
+	Metadata MetadataConfig `json:"metadata"`
 }
 
 func (config *Configs) LoadConfig(path string) error {
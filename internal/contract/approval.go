@@ -0,0 +1,257 @@
+package contract
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"utopia/contracts/token"
+	"utopia/internal/chain"
+	"utopia/internal/excel"
+	"utopia/internal/logger"
+	"utopia/internal/wallet"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	APPROVAL_ERC20    = "erc20"    // Allowance of erc20 token
+	APPROVAL_OPERATOR = "operator" // Operator of all erc721 or erc1155 tokens
+
+	// number of owners in one log query
+	APPROVAL_OWNER_BATCH = 100
+)
+
+var (
+	APPROVAL_SHEET_NAME  = "approvals"
+	APPROVAL_LIST_HEADER = []string{"index", "owner", "token", "kind", "spender", "allowance", "block"}
+)
+
+// outstanding approval of owner
+type Approval struct {
+	Owner     string
+	Token     string
+	Kind      string   // APPROVAL_ERC20 or APPROVAL_OPERATOR
+	Spender   string   // Spender of erc20 or operator of nft
+	Allowance *big.Int // Live allowance of erc20, nil for operator
+	Block     uint64   // Block of last approval event
+}
+
+// find approvals of owners by Approval and ApprovalForAll logs from block and keep the ones still effective on chain,
+// tokens limit the contracts to scan and all contracts are scanned if empty
+func ScanApprovals(ctx context.Context, c chain.Chain, owners []string, tokens []string, from uint64) ([]*Approval, error) {
+	evm, ok := c.(chain.EvmChain)
+	if !ok {
+		return nil, errors.New("Approval only support evm chain")
+	}
+
+	erc20, err := token.ERC20MetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	erc721, err := token.ERC721MetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]common.Address, 0, len(tokens))
+	for _, address := range tokens {
+		addresses = append(addresses, common.HexToAddress(address))
+	}
+
+	// the last approval event of each token, owner, spender and kind
+	approvals := make(map[string]*Approval)
+	for i := 0; i < len(owners); i += APPROVAL_OWNER_BATCH {
+		end := i + APPROVAL_OWNER_BATCH
+		if end > len(owners) {
+			end = len(owners)
+		}
+
+		topics := make([]common.Hash, 0, end-i)
+		for _, owner := range owners[i:end] {
+			topics = append(topics, common.HexToAddress(owner).Hash())
+		}
+
+		logs, err := evm.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			Addresses: addresses,
+			Topics:    [][]common.Hash{{erc20.Events["Approval"].ID, erc721.Events["ApprovalForAll"].ID}, topics},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, log := range logs {
+			// the erc721 Approval of single token has 4 topics and is cleared on transfer
+			approval := &Approval{Token: log.Address.Hex(), Block: log.BlockNumber}
+			switch {
+			case log.Topics[0] == erc20.Events["Approval"].ID && len(log.Topics) == 3:
+				approval.Kind = APPROVAL_ERC20
+			case log.Topics[0] == erc721.Events["ApprovalForAll"].ID && len(log.Topics) == 3:
+				approval.Kind = APPROVAL_OPERATOR
+			default:
+				continue
+			}
+
+			approval.Owner = common.BytesToAddress(log.Topics[1].Bytes()).Hex()
+			approval.Spender = common.BytesToAddress(log.Topics[2].Bytes()).Hex()
+			approvals[strings.Join([]string{approval.Token, approval.Owner, approval.Spender, approval.Kind}, ",")] = approval
+		}
+	}
+
+	// check live allowance since it is changed by transferFrom without event
+	result := make([]*Approval, 0)
+	for _, approval := range approvals {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		owner, spender := common.HexToAddress(approval.Owner), common.HexToAddress(approval.Spender)
+		if approval.Kind == APPROVAL_ERC20 {
+			value, err := tokenCall(ctx, c, erc20, common.HexToAddress(approval.Token), "allowance", owner, spender)
+			if err != nil {
+				if chain.IsTransportError(err) {
+					return nil, err
+				}
+
+				// not erc20 contract
+				logger.Warn("Query allowance of %s failed with err: %v", approval.Token, err)
+				continue
+			}
+
+			approval.Allowance = value.(*big.Int)
+			if approval.Allowance.Sign() > 0 {
+				result = append(result, approval)
+			}
+		} else {
+			// isApprovedForAll is the same in erc721 and erc1155
+			value, err := tokenCall(ctx, c, erc721, common.HexToAddress(approval.Token), "isApprovedForAll", owner, spender)
+			if err != nil {
+				if chain.IsTransportError(err) {
+					return nil, err
+				}
+
+				logger.Warn("Query operator of %s failed with err: %v", approval.Token, err)
+				continue
+			}
+
+			if value.(bool) {
+				result = append(result, approval)
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Owner != result[j].Owner {
+			return result[i].Owner < result[j].Owner
+		}
+
+		if result[i].Token != result[j].Token {
+			return result[i].Token < result[j].Token
+		}
+
+		return result[i].Spender < result[j].Spender
+	})
+
+	return result, nil
+}
+
+// revoke approval by owner wallet, the erc20 allowance is set to 0 and the operator is disabled
+func RevokeApproval(ctx context.Context, c chain.Chain, approval *Approval, wallet wallet.Wallet) (string, error) {
+	if common.HexToAddress(approval.Owner) != common.HexToAddress(wallet.Address()) {
+		return "", errors.New("Wallet is not owner " + approval.Owner)
+	}
+
+	switch approval.Kind {
+	case APPROVAL_ERC20:
+		return NewERC20(c, approval.Token).(*ERC20Contract).Approve(ctx, approval.Spender, big.NewInt(0), wallet)
+	case APPROVAL_OPERATOR:
+		// setApprovalForAll is the same in erc721 and erc1155
+		return NewERC1155(c, approval.Token).(*ERC1155Contract).Approve(ctx, approval.Spender, false, wallet)
+	}
+
+	return "", errors.New("Not support approval kind " + approval.Kind)
+}
+
+func ReadApprovalFile(path string) ([]*Approval, error) {
+	// open excel file to read list
+	file, err := excel.NewExcel(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close(false)
+
+	data, err := file.ReadAll(APPROVAL_SHEET_NAME)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Approval, 0)
+	for index, row := range data {
+		// skip the header
+		if index == 0 {
+			continue
+		}
+
+		if len(row) < len(APPROVAL_LIST_HEADER) {
+			return nil, errors.New("Invalid file format")
+		}
+
+		// {"index", "owner", "token", "kind", "spender", "allowance", "block"}
+		approval := &Approval{Owner: row[1], Token: row[2], Kind: row[3], Spender: row[4]}
+		if approval.Kind == APPROVAL_ERC20 {
+			approval.Allowance, _ = new(big.Int).SetString(row[5], 10)
+		}
+		approval.Block, _ = strconv.ParseUint(row[6], 10, 64)
+
+		result = append(result, approval)
+	}
+
+	return result, nil
+}
+
+func SaveApprovalFile(approvals []*Approval, path string) error {
+	// open excel file to write list
+	file, err := excel.NewExcel(path)
+	if err != nil {
+		return err
+	}
+
+	err = file.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close(true)
+
+	data := make([][]string, 0, len(approvals)+1)
+	data = append(data, APPROVAL_LIST_HEADER)
+	for i, approval := range approvals {
+		// the operator is approved for all tokens
+		allowance := "all"
+		if approval.Allowance != nil {
+			allowance = approval.Allowance.String()
+		}
+
+		row := make([]string, 0, len(APPROVAL_LIST_HEADER))
+		row = append(row, strconv.Itoa(i+1))
+		row = append(row, approval.Owner)
+		row = append(row, approval.Token)
+		row = append(row, approval.Kind)
+		row = append(row, approval.Spender)
+		row = append(row, allowance)
+		row = append(row, strconv.FormatUint(approval.Block, 10))
+
+		data = append(data, row)
+	}
+
+	return file.WriteAll(APPROVAL_SHEET_NAME, data)
+}
@@ -1,24 +1,30 @@
 package contract
 
 import (
+	"context"
 	"math/big"
+	"utopia/internal/chain"
 	"utopia/internal/wallet"
 )
 
 const (
-	COMMON_CRONTACT = 1
-	ERC20_CONTRACT  = 2
-	ERC721_CONTRACT = 3
+	COMMON_CRONTACT  = 1
+	ERC20_CONTRACT   = 2
+	ERC721_CONTRACT  = 3
+	ERC1155_CONTRACT = 4
 )
 
 // contract interface
 type Contract interface {
 	Address() string
-	Code() (string, error)
+	Code(ctx context.Context) (string, error)
 	ABI() string
 	SetABI(path string) error
 	EncodeABI(method string, data string, withfunc bool) (string, error)
 	DecodeABI(method string, data string, withfunc bool) (string, error)
-	Deploy(code string, params string, wallet wallet.Wallet, value *big.Int) (string, error)
-	Call(params string, wallet wallet.Wallet, value *big.Int) ([]interface{}, error)
+	Deploy(ctx context.Context, code string, params string, wallet wallet.Wallet, value *big.Int) (string, string, error)
+	Call(ctx context.Context, params string, wallet wallet.Wallet, value *big.Int) (*CallResult, error)
+	DryRunDeploy(ctx context.Context, code string, params string, wallet wallet.Wallet, value *big.Int) (*chain.Simulation, error)
+	DryRunCall(ctx context.Context, params string, wallet wallet.Wallet, value *big.Int) (*chain.Simulation, error)
+	Events(ctx context.Context, name string, from uint64, to uint64) ([]*Event, error)
 }
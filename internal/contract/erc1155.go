@@ -0,0 +1,185 @@
+package contract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"utopia/contracts/token"
+	"utopia/internal/chain"
+	"utopia/internal/wallet"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type ERC1155Contract struct {
+	chain    chain.Chain    // Chain id which contract deployed
+	address  common.Address // Contract address
+	contract *token.ERC1155
+}
+
+func NewERC1155(c chain.Chain, address string) Contract {
+	erc1155 := &ERC1155Contract{
+		chain:    c,
+		address:  common.HexToAddress(address),
+		contract: nil,
+	}
+
+	// bind token on evm chain
+	if ethchain, ok := c.(*chain.EthChain); ok {
+		erc1155.contract, _ = token.NewERC1155(erc1155.address, ethchain.Backend())
+	}
+
+	return erc1155
+}
+
+func (c *ERC1155Contract) Address() string {
+	return c.address.Hex()
+}
+
+func (c *ERC1155Contract) Code(ctx context.Context) (string, error) {
+	return c.chain.Code(ctx, c.address.Hex())
+}
+
+func (c *ERC1155Contract) ABI() string {
+	return token.ERC1155ABI
+}
+
+// not support under functions
+func (c *ERC1155Contract) SetABI(path string) error {
+	return errors.New("Not support")
+}
+
+func (c *ERC1155Contract) EncodeABI(method string, data string, withfunc bool) (string, error) {
+	return "", errors.New("Not support")
+}
+
+func (c *ERC1155Contract) DecodeABI(method string, data string, withfunc bool) (string, error) {
+	return "", errors.New("Not support")
+}
+
+func (c *ERC1155Contract) Deploy(ctx context.Context, code string, params string, wallet wallet.Wallet, value *big.Int) (string, string, error) {
+	return "", "", errors.New("Not support")
+}
+
+func (c *ERC1155Contract) Call(ctx context.Context, params string, wallet wallet.Wallet, value *big.Int) (*CallResult, error) {
+	return nil, errors.New("Not support")
+}
+
+func (c *ERC1155Contract) DryRunDeploy(ctx context.Context, code string, params string, wallet wallet.Wallet, value *big.Int) (*chain.Simulation, error) {
+	return nil, errors.New("Not support")
+}
+
+func (c *ERC1155Contract) DryRunCall(ctx context.Context, params string, wallet wallet.Wallet, value *big.Int) (*chain.Simulation, error) {
+	return nil, errors.New("Not support")
+}
+
+// query events with bundled abi
+func (c *ERC1155Contract) Events(ctx context.Context, name string, from uint64, to uint64) ([]*Event, error) {
+	parsed, err := token.ERC1155MetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	return QueryEvents(ctx, c.chain, c.address.Hex(), parsed, name, from, to)
+}
+
+// query token amount of id owned by address
+func (c *ERC1155Contract) Balance(ctx context.Context, address string, id *big.Int) (*big.Int, error) {
+	if c.contract == nil {
+		return nil, errors.New("Token only support evm chain")
+	}
+
+	return c.contract.BalanceOf(&bind.CallOpts{Context: ctx}, common.HexToAddress(address), id)
+}
+
+// query token amounts of address and id pairs in one call
+func (c *ERC1155Contract) Balances(ctx context.Context, addresses []string, ids []*big.Int) ([]*big.Int, error) {
+	if c.contract == nil {
+		return nil, errors.New("Token only support evm chain")
+	}
+
+	if len(addresses) != len(ids) {
+		return nil, errors.New("Not match number of accounts and ids")
+	}
+
+	accounts := make([]common.Address, 0, len(addresses))
+	for _, address := range addresses {
+		accounts = append(accounts, common.HexToAddress(address))
+	}
+
+	return c.contract.BalanceOfBatch(&bind.CallOpts{Context: ctx}, accounts, ids)
+}
+
+// query operator is approved for all tokens of owner
+func (c *ERC1155Contract) IsApprovedForAll(ctx context.Context, owner string, operator string) (bool, error) {
+	if c.contract == nil {
+		return false, errors.New("Token only support evm chain")
+	}
+
+	return c.contract.IsApprovedForAll(&bind.CallOpts{Context: ctx}, common.HexToAddress(owner), common.HexToAddress(operator))
+}
+
+// query metadata url of token, the {id} is replaced by lowercase hex id padded to 64 characters
+func (c *ERC1155Contract) TokenUrl(ctx context.Context, id *big.Int) (string, error) {
+	if c.contract == nil {
+		return "", errors.New("Token only support evm chain")
+	}
+
+	uri, err := c.contract.Uri(&bind.CallOpts{Context: ctx}, id)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ReplaceAll(uri, "{id}", fmt.Sprintf("%064x", id)), nil
+}
+
+// transfer amount of token id to receiver by safeTransferFrom
+func (c *ERC1155Contract) Transfer(ctx context.Context, to string, id *big.Int, amount *big.Int, data []byte, wallet wallet.Wallet) (string, error) {
+	return c.transact(ctx, wallet, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contract.SafeTransferFrom(opts, common.HexToAddress(wallet.Address()), common.HexToAddress(to), id, amount, data)
+	})
+}
+
+// transfer amounts of token ids to receiver in one transaction by safeBatchTransferFrom
+func (c *ERC1155Contract) BatchTransfer(ctx context.Context, to string, ids []*big.Int, amounts []*big.Int, data []byte, wallet wallet.Wallet) (string, error) {
+	if len(ids) != len(amounts) {
+		return "", errors.New("Not match number of ids and amounts")
+	}
+
+	return c.transact(ctx, wallet, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contract.SafeBatchTransferFrom(opts, common.HexToAddress(wallet.Address()), common.HexToAddress(to), ids, amounts, data)
+	})
+}
+
+// approve or revoke operator for all tokens
+func (c *ERC1155Contract) Approve(ctx context.Context, to string, approve bool, wallet wallet.Wallet) (string, error) {
+	return c.transact(ctx, wallet, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contract.SetApprovalForAll(opts, common.HexToAddress(to), approve)
+	})
+}
+
+// send transaction of binding with local nonce
+func (c *ERC1155Contract) transact(ctx context.Context, wallet wallet.Wallet, send func(opts *bind.TransactOpts) (*types.Transaction, error)) (string, error) {
+	if c.contract == nil {
+		return "", errors.New("Token only support evm chain")
+	}
+
+	opts, err := c.chain.(*chain.EthChain).GenTransOpts(ctx, wallet, nil)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := c.chain.(*chain.EthChain).Transact(ctx, wallet.Address(), func(nonce uint64) (*types.Transaction, error) {
+		opts.Nonce = new(big.Int).SetUint64(nonce)
+		return send(opts)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return tx.Hash().Hex(), nil
+}
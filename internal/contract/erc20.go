@@ -1,35 +1,56 @@
 package contract
 
 import (
+	"context"
 	"errors"
 	"math/big"
 	"utopia/contracts/token"
 	"utopia/internal/chain"
+	"utopia/internal/helper"
 	"utopia/internal/wallet"
 
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 )
 
 type ERC20Contract struct {
 	chain    chain.Chain    // Chain id which contract deployed
 	address  common.Address // Contract address
 	contract *token.ERC20
+	decimals *uint8 // Cached decimals of token
 }
 
-func NewERC20(chain chain.Chain, address string) Contract {
-	return &ERC20Contract{
-		chain:    chain,
+// token properties
+type TokenInfo struct {
+	Address     string
+	Name        string
+	Symbol      string
+	Decimals    uint8
+	TotalSupply *big.Int
+}
+
+func NewERC20(c chain.Chain, address string) Contract {
+	erc20 := &ERC20Contract{
+		chain:    c,
 		address:  common.HexToAddress(address),
 		contract: nil,
 	}
+
+	// bind token on evm chain
+	if ethchain, ok := c.(*chain.EthChain); ok {
+		erc20.contract, _ = token.NewERC20(erc20.address, ethchain.Backend())
+	}
+
+	return erc20
 }
 
 func (c *ERC20Contract) Address() string {
 	return c.address.Hex()
 }
 
-func (c *ERC20Contract) Code() (string, error) {
-	return c.chain.Code(c.address.Hex())
+func (c *ERC20Contract) Code(ctx context.Context) (string, error) {
+	return c.chain.Code(ctx, c.address.Hex())
 }
 
 func (c *ERC20Contract) ABI() string {
@@ -49,27 +70,163 @@ func (c *ERC20Contract) DecodeABI(method string, data string, withfunc bool) (st
 	return "", errors.New("Not support")
 }
 
-func (c *ERC20Contract) Deploy(code string, params string, wallet wallet.Wallet, value *big.Int) (string, error) {
-	return "", errors.New("Not support")
+func (c *ERC20Contract) Deploy(ctx context.Context, code string, params string, wallet wallet.Wallet, value *big.Int) (string, string, error) {
+	return "", "", errors.New("Not support")
+}
+
+func (c *ERC20Contract) Call(ctx context.Context, params string, wallet wallet.Wallet, value *big.Int) (*CallResult, error) {
+	return nil, errors.New("Not support")
+}
+
+func (c *ERC20Contract) DryRunDeploy(ctx context.Context, code string, params string, wallet wallet.Wallet, value *big.Int) (*chain.Simulation, error) {
+	return nil, errors.New("Not support")
 }
 
-func (c *ERC20Contract) Call(params string, wallet wallet.Wallet, value *big.Int) ([]interface{}, error) {
+func (c *ERC20Contract) DryRunCall(ctx context.Context, params string, wallet wallet.Wallet, value *big.Int) (*chain.Simulation, error) {
 	return nil, errors.New("Not support")
 }
 
+// query events with bundled abi
+func (c *ERC20Contract) Events(ctx context.Context, name string, from uint64, to uint64) ([]*Event, error) {
+	parsed, err := token.ERC20MetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	return QueryEvents(ctx, c.chain, c.address.Hex(), parsed, name, from, to)
+}
+
 // query token balance of owner
-func (c *ERC20Contract) Balance(address string) (*big.Int, error) {
-	return c.contract.BalanceOf(nil, common.HexToAddress(address))
+func (c *ERC20Contract) Balance(ctx context.Context, address string) (*big.Int, error) {
+	if c.contract == nil {
+		return nil, errors.New("Token only support evm chain")
+	}
+
+	return c.contract.BalanceOf(&bind.CallOpts{Context: ctx}, common.HexToAddress(address))
+}
+
+// query amount of owner approved to spender
+func (c *ERC20Contract) Allowance(ctx context.Context, owner string, spender string) (*big.Int, error) {
+	if c.contract == nil {
+		return nil, errors.New("Token only support evm chain")
+	}
+
+	return c.contract.Allowance(&bind.CallOpts{Context: ctx}, common.HexToAddress(owner), common.HexToAddress(spender))
+}
+
+// query decimals of token, the value is cached
+func (c *ERC20Contract) Decimals(ctx context.Context) (uint8, error) {
+	if c.decimals != nil {
+		return *c.decimals, nil
+	}
+
+	if c.contract == nil {
+		return 0, errors.New("Token only support evm chain")
+	}
+
+	decimals, err := c.contract.Decimals(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return 0, err
+	}
+
+	c.decimals = &decimals
+	return decimals, nil
+}
+
+// query name, symbol, decimals and total supply of token
+func (c *ERC20Contract) Info(ctx context.Context) (*TokenInfo, error) {
+	decimals, err := c.Decimals(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+	info := &TokenInfo{Address: c.address.Hex(), Decimals: decimals}
+	info.Name, err = c.contract.Name(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	info.Symbol, err = c.contract.Symbol(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	info.TotalSupply, err = c.contract.TotalSupply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// parse human amount to minimal unit by decimals of token, like 1.5 to 1500000 for 6 decimals
+func (c *ERC20Contract) ParseAmount(ctx context.Context, amount string) (*big.Int, error) {
+	decimals, err := c.Decimals(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return helper.ParseAmount(amount, int(decimals))
+}
+
+// format amount in minimal unit to human amount by decimals of token
+func (c *ERC20Contract) FormatAmount(ctx context.Context, value *big.Int) (string, error) {
+	decimals, err := c.Decimals(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return helper.FormatAmount(value, int(decimals)), nil
+}
+
+// query token balance of owners in multicall, the balance is nil if failed
+func (c *ERC20Contract) Balances(ctx context.Context, addresses []string) ([]*big.Int, error) {
+	m, err := NewMulticall(c.chain)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := token.ERC20MetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	calls := make([]*MultiCall, 0, len(addresses))
+	for _, address := range addresses {
+		calls = append(calls, &MultiCall{Target: c.address.Hex(), ABI: parsed, Method: "balanceOf", Args: []interface{}{common.HexToAddress(address)}})
+	}
+
+	results, err := m.Call(ctx, calls)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]*big.Int, len(results))
+	for i, result := range results {
+		if result.Success {
+			balances[i] = result.Values[0].(*big.Int)
+		}
+	}
+
+	return balances, nil
 }
 
 // transfer token to receiver
-func (c *ERC20Contract) Transfer(to string, value *big.Int, wallet wallet.Wallet) (string, error) {
-	opts, err := c.chain.(*chain.EthChain).GenTransOpts(wallet, nil)
+func (c *ERC20Contract) Transfer(ctx context.Context, to string, value *big.Int, wallet wallet.Wallet) (string, error) {
+	if c.contract == nil {
+		return "", errors.New("Token only support evm chain")
+	}
+
+	opts, err := c.chain.(*chain.EthChain).GenTransOpts(ctx, wallet, nil)
 	if err != nil {
 		return "", err
 	}
 
-	tx, err := c.contract.Transfer(opts, common.HexToAddress(to), value)
+	tx, err := c.chain.(*chain.EthChain).Transact(ctx, wallet.Address(), func(nonce uint64) (*types.Transaction, error) {
+		opts.Nonce = new(big.Int).SetUint64(nonce)
+		return c.contract.Transfer(opts, common.HexToAddress(to), value)
+	})
 	if err != nil {
 		return "", err
 	}
@@ -77,14 +234,46 @@ func (c *ERC20Contract) Transfer(to string, value *big.Int, wallet wallet.Wallet
 	return tx.Hash().Hex(), nil
 }
 
+// simulate token transfer with the expected token balance changes
+func (c *ERC20Contract) DryRunTransfer(ctx context.Context, to string, value *big.Int, wallet wallet.Wallet) (*chain.Simulation, error) {
+	parsed, err := token.ERC20MetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := parsed.Pack("transfer", common.HexToAddress(to), value)
+	if err != nil {
+		return nil, err
+	}
+
+	sim, err := simulate(ctx, c.chain, wallet.Address(), &c.address, nil, input)
+	if err != nil || !sim.Success {
+		return sim, err
+	}
+
+	decimals, err := c.Decimals(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return sim, addTokenChanges(ctx, c.chain, sim, parsed, c.address, int(decimals), wallet.Address(), to, value)
+}
+
 // approve token to receiver
-func (c *ERC20Contract) Approve(to string, value *big.Int, wallet wallet.Wallet) (string, error) {
-	opts, err := c.chain.(*chain.EthChain).GenTransOpts(wallet, nil)
+func (c *ERC20Contract) Approve(ctx context.Context, to string, value *big.Int, wallet wallet.Wallet) (string, error) {
+	if c.contract == nil {
+		return "", errors.New("Token only support evm chain")
+	}
+
+	opts, err := c.chain.(*chain.EthChain).GenTransOpts(ctx, wallet, nil)
 	if err != nil {
 		return "", err
 	}
 
-	tx, err := c.contract.Approve(opts, common.HexToAddress(to), value)
+	tx, err := c.chain.(*chain.EthChain).Transact(ctx, wallet.Address(), func(nonce uint64) (*types.Transaction, error) {
+		opts.Nonce = new(big.Int).SetUint64(nonce)
+		return c.contract.Approve(opts, common.HexToAddress(to), value)
+	})
 	if err != nil {
 		return "", err
 	}
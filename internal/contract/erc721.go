@@ -1,19 +1,46 @@
 package contract
 
 import (
+	"context"
 	"errors"
 	"math/big"
+	"sort"
+	"strconv"
+	"strings"
 	"utopia/contracts/token"
 	"utopia/internal/chain"
+	"utopia/internal/excel"
 	"utopia/internal/wallet"
 
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	ERC721_ENUMERABLE_INTERFACE = "0x780e9d63" // ERC165 interface id of ERC721Enumerable
+	INVENTORY_ACCOUNT_BATCH     = 100          // Number of accounts in one log query
+	erc721EnumerableABI         = `[{"inputs":[{"internalType":"bytes4","name":"interfaceId","type":"bytes4"}],"name":"supportsInterface","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"owner","type":"address"},{"internalType":"uint256","name":"index","type":"uint256"}],"name":"tokenOfOwnerByIndex","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+)
+
+var (
+	INVENTORY_SHEET_NAME  = "inventory"
+	INVENTORY_LIST_HEADER = []string{"index", "account", "contract", "tokenid"}
 )
 
 type ERC721Contract struct {
-	chain    chain.Chain    // Chain id which contract deployed
-	address  common.Address // Contract address
-	contract *token.ERC721
+	chain      chain.Chain    // Chain id which contract deployed
+	address    common.Address // Contract address
+	contract   *token.ERC721
+	enumerable *bool // Cached result of ERC165 check
+}
+
+// tokens owned by account
+type ERC721Holding struct {
+	Account  string
+	TokenIds []*big.Int
 }
 
 type ERC721Attr struct {
@@ -28,24 +55,31 @@ type ERC721Meta struct {
 	Attributes  []ERC721Attr `json:"attributes"`
 }
 
-func NewERC721(chain chain.Chain, address string) Contract {
-	return &ERC721Contract{
-		chain:    chain,
+func NewERC721(c chain.Chain, address string) Contract {
+	erc721 := &ERC721Contract{
+		chain:    c,
 		address:  common.HexToAddress(address),
 		contract: nil,
 	}
+
+	// bind token on evm chain
+	if ethchain, ok := c.(*chain.EthChain); ok {
+		erc721.contract, _ = token.NewERC721(erc721.address, ethchain.Backend())
+	}
+
+	return erc721
 }
 
 func (c *ERC721Contract) Address() string {
 	return c.address.Hex()
 }
 
-func (c *ERC721Contract) Code() (string, error) {
-	return c.chain.Code(c.address.Hex())
+func (c *ERC721Contract) Code(ctx context.Context) (string, error) {
+	return c.chain.Code(ctx, c.address.Hex())
 }
 
 func (c *ERC721Contract) ABI() string {
-	return token.ERC20ABI
+	return token.ERC721ABI
 }
 
 // not support under functions
@@ -61,17 +95,39 @@ func (c *ERC721Contract) DecodeABI(method string, data string, withfunc bool) (s
 	return "", errors.New("Not support")
 }
 
-func (c *ERC721Contract) Deploy(code string, params string, wallet wallet.Wallet, value *big.Int) (string, error) {
-	return "", errors.New("Not support")
+func (c *ERC721Contract) Deploy(ctx context.Context, code string, params string, wallet wallet.Wallet, value *big.Int) (string, string, error) {
+	return "", "", errors.New("Not support")
 }
 
-func (c *ERC721Contract) Call(params string, wallet wallet.Wallet, value *big.Int) ([]interface{}, error) {
+func (c *ERC721Contract) Call(ctx context.Context, params string, wallet wallet.Wallet, value *big.Int) (*CallResult, error) {
 	return nil, errors.New("Not support")
 }
 
+func (c *ERC721Contract) DryRunDeploy(ctx context.Context, code string, params string, wallet wallet.Wallet, value *big.Int) (*chain.Simulation, error) {
+	return nil, errors.New("Not support")
+}
+
+func (c *ERC721Contract) DryRunCall(ctx context.Context, params string, wallet wallet.Wallet, value *big.Int) (*chain.Simulation, error) {
+	return nil, errors.New("Not support")
+}
+
+// query events with bundled abi
+func (c *ERC721Contract) Events(ctx context.Context, name string, from uint64, to uint64) ([]*Event, error) {
+	parsed, err := token.ERC721MetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	return QueryEvents(ctx, c.chain, c.address.Hex(), parsed, name, from, to)
+}
+
 // query token number which owned by address
-func (c *ERC721Contract) Balance(address string) (uint64, error) {
-	balance, err := c.contract.BalanceOf(nil, common.HexToAddress(address))
+func (c *ERC721Contract) Balance(ctx context.Context, address string) (uint64, error) {
+	if c.contract == nil {
+		return 0, errors.New("Token only support evm chain")
+	}
+
+	balance, err := c.contract.BalanceOf(&bind.CallOpts{Context: ctx}, common.HexToAddress(address))
 	if err != nil {
 		return 0, err
 	}
@@ -79,14 +135,214 @@ func (c *ERC721Contract) Balance(address string) (uint64, error) {
 	return balance.Uint64(), nil
 }
 
+// check the contract implements ERC721Enumerable by ERC165, the contract without ERC165 is not enumerable
+func (c *ERC721Contract) IsEnumerable(ctx context.Context) (bool, error) {
+	if c.enumerable != nil {
+		return *c.enumerable, nil
+	}
+
+	if c.contract == nil {
+		return false, errors.New("Token only support evm chain")
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(erc721EnumerableABI))
+	if err != nil {
+		return false, err
+	}
+
+	var id [4]byte
+	copy(id[:], common.FromHex(ERC721_ENUMERABLE_INTERFACE))
+
+	// revert or invalid output means ERC165 is not implemented
+	enumerable := false
+	result, err := tokenCall(ctx, c.chain, &parsed, c.address, "supportsInterface", id)
+	if err == nil {
+		enumerable = result.(bool)
+	} else if chain.IsTransportError(err) {
+		return false, err
+	}
+
+	c.enumerable = &enumerable
+	return enumerable, nil
+}
+
 // this function need enumable 721 contract
-func (c *ERC721Contract) TokenIdByIndex(address string, index uint32) (uint64, error) {
-	return 0, nil
+func (c *ERC721Contract) TokenIdByIndex(ctx context.Context, address string, index uint32) (*big.Int, error) {
+	parsed, err := abi.JSON(strings.NewReader(erc721EnumerableABI))
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tokenCall(ctx, c.chain, &parsed, c.address, "tokenOfOwnerByIndex", common.HexToAddress(address), new(big.Int).SetUint64(uint64(index)))
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*big.Int), nil
+}
+
+// list token ids owned by address, the tokens of not enumerable contract are found by Transfer logs from block and checked by owner
+func (c *ERC721Contract) TokensOf(ctx context.Context, address string, from uint64) ([]*big.Int, error) {
+	enumerable, err := c.IsEnumerable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if enumerable {
+		balance, err := c.Balance(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+
+		tokenids := make([]*big.Int, 0, balance)
+		for i := uint64(0); i < balance; i++ {
+			tokenid, err := c.TokenIdByIndex(ctx, address, uint32(i))
+			if err != nil {
+				return nil, err
+			}
+
+			tokenids = append(tokenids, tokenid)
+		}
+
+		return tokenids, nil
+	}
+
+	holdings, err := c.tokensByLogs(ctx, []string{address}, from)
+	if err != nil {
+		return nil, err
+	}
+
+	return holdings[common.HexToAddress(address)], nil
+}
+
+// tokens ever received by addresses and still owned, the logs are queried by batch of addresses
+func (c *ERC721Contract) tokensByLogs(ctx context.Context, addresses []string, from uint64) (map[common.Address][]*big.Int, error) {
+	evm, ok := c.chain.(chain.EvmChain)
+	if !ok {
+		return nil, errors.New("Token only support evm chain")
+	}
+
+	parsed, err := token.ERC721MetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make(map[common.Address]bool)
+	for _, address := range addresses {
+		accounts[common.HexToAddress(address)] = true
+	}
+
+	// token ids received by any of addresses
+	received := make([]common.Hash, 0)
+	checked := make(map[common.Hash]bool)
+	for i := 0; i < len(addresses); i += INVENTORY_ACCOUNT_BATCH {
+		end := i + INVENTORY_ACCOUNT_BATCH
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+
+		topics := make([]common.Hash, 0, end-i)
+		for _, address := range addresses[i:end] {
+			topics = append(topics, common.HexToAddress(address).Hash())
+		}
+
+		// Transfer(from, to, tokenId) with all arguments indexed
+		logs, err := evm.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			Addresses: []common.Address{c.address},
+			Topics:    [][]common.Hash{{parsed.Events["Transfer"].ID}, {}, topics},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, log := range logs {
+			if len(log.Topics) != 4 || checked[log.Topics[3]] {
+				continue
+			}
+			checked[log.Topics[3]] = true
+			received = append(received, log.Topics[3])
+		}
+	}
+
+	// group tokens by current owner
+	holdings := make(map[common.Address][]*big.Int)
+	for _, hash := range received {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		tokenid := hash.Big()
+		owner, err := c.contract.OwnerOf(&bind.CallOpts{Context: ctx}, tokenid)
+		if err != nil {
+			// burned token
+			if chain.IsTransportError(err) {
+				return nil, err
+			}
+			continue
+		}
+
+		if accounts[owner] {
+			holdings[owner] = append(holdings[owner], tokenid)
+		}
+	}
+
+	for _, tokenids := range holdings {
+		sort.Slice(tokenids, func(i, j int) bool {
+			return tokenids[i].Cmp(tokenids[j]) < 0
+		})
+	}
+
+	return holdings, nil
+}
+
+// list tokens owned by each address, the logs from block are scanned if not enumerable
+func (c *ERC721Contract) Inventory(ctx context.Context, addresses []string, from uint64) ([]*ERC721Holding, error) {
+	enumerable, err := c.IsEnumerable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens map[common.Address][]*big.Int
+	if !enumerable {
+		tokens, err = c.tokensByLogs(ctx, addresses, from)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	holdings := make([]*ERC721Holding, 0, len(addresses))
+	for _, address := range addresses {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		account := common.HexToAddress(address)
+		tokenids := tokens[account]
+		if enumerable {
+			tokenids, err = c.TokensOf(ctx, address, from)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if tokenids == nil {
+			tokenids = make([]*big.Int, 0)
+		}
+
+		holdings = append(holdings, &ERC721Holding{Account: account.Hex(), TokenIds: tokenids})
+	}
+
+	return holdings, nil
 }
 
 // query owner of token
-func (c *ERC721Contract) Owner(tokenid uint64) (string, error) {
-	address, err := c.contract.OwnerOf(nil, new(big.Int).SetUint64(tokenid))
+func (c *ERC721Contract) Owner(ctx context.Context, tokenid *big.Int) (string, error) {
+	if c.contract == nil {
+		return "", errors.New("Token only support evm chain")
+	}
+
+	address, err := c.contract.OwnerOf(&bind.CallOpts{Context: ctx}, tokenid)
 	if err != nil {
 		return "", err
 	}
@@ -95,18 +351,77 @@ func (c *ERC721Contract) Owner(tokenid uint64) (string, error) {
 }
 
 // query token url
-func (c *ERC721Contract) TokenUrl(tokenid uint64) (string, error) {
-	return c.contract.TokenURI(nil, new(big.Int).SetUint64(tokenid))
+func (c *ERC721Contract) TokenUrl(ctx context.Context, tokenid *big.Int) (string, error) {
+	if c.contract == nil {
+		return "", errors.New("Token only support evm chain")
+	}
+
+	return c.contract.TokenURI(&bind.CallOpts{Context: ctx}, tokenid)
 }
 
 // transfer token from owner to receiver
-func (c *ERC721Contract) Transfer(to string, tokenid uint64, wallet wallet.Wallet) (string, error) {
-	opts, err := c.chain.(*chain.EthChain).GenTransOpts(wallet, nil)
+func (c *ERC721Contract) Transfer(ctx context.Context, to string, tokenid *big.Int, wallet wallet.Wallet) (string, error) {
+	return c.transact(ctx, wallet, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contract.TransferFrom(opts, common.HexToAddress(wallet.Address()), common.HexToAddress(to), tokenid)
+	})
+}
+
+// transfer token by safeTransferFrom, the receiver contract must accept it by onERC721Received with data
+func (c *ERC721Contract) SafeTransfer(ctx context.Context, to string, tokenid *big.Int, data []byte, wallet wallet.Wallet) (string, error) {
+	return c.transact(ctx, wallet, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contract.SafeTransferFrom0(opts, common.HexToAddress(wallet.Address()), common.HexToAddress(to), tokenid, data)
+	})
+}
+
+// simulate token transfer with the expected token number changes
+func (c *ERC721Contract) DryRunTransfer(ctx context.Context, to string, tokenid *big.Int, wallet wallet.Wallet) (*chain.Simulation, error) {
+	parsed, err := token.ERC721MetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := parsed.Pack("transferFrom", common.HexToAddress(wallet.Address()), common.HexToAddress(to), tokenid)
+	if err != nil {
+		return nil, err
+	}
+
+	sim, err := simulate(ctx, c.chain, wallet.Address(), &c.address, nil, input)
+	if err != nil || !sim.Success {
+		return sim, err
+	}
+
+	return sim, addTokenChanges(ctx, c.chain, sim, parsed, c.address, 0, wallet.Address(), to, big.NewInt(1))
+}
+
+// approve token to receiver
+func (c *ERC721Contract) Approve(ctx context.Context, to string, tokenid *big.Int, wallet wallet.Wallet) (string, error) {
+	return c.transact(ctx, wallet, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contract.Approve(opts, common.HexToAddress(to), tokenid)
+	})
+}
+
+// approve or revoke operator for all tokens
+func (c *ERC721Contract) ApproveAll(ctx context.Context, to string, approve bool, wallet wallet.Wallet) (string, error) {
+	return c.transact(ctx, wallet, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contract.SetApprovalForAll(opts, common.HexToAddress(to), approve)
+	})
+}
+
+// send transaction of binding with local nonce
+func (c *ERC721Contract) transact(ctx context.Context, wallet wallet.Wallet, send func(opts *bind.TransactOpts) (*types.Transaction, error)) (string, error) {
+	if c.contract == nil {
+		return "", errors.New("Token only support evm chain")
+	}
+
+	opts, err := c.chain.(*chain.EthChain).GenTransOpts(ctx, wallet, nil)
 	if err != nil {
 		return "", err
 	}
 
-	tx, err := c.contract.TransferFrom(opts, common.HexToAddress(wallet.Address()), common.HexToAddress(to), new(big.Int).SetUint64(tokenid))
+	tx, err := c.chain.(*chain.EthChain).Transact(ctx, wallet.Address(), func(nonce uint64) (*types.Transaction, error) {
+		opts.Nonce = new(big.Int).SetUint64(nonce)
+		return send(opts)
+	})
 	if err != nil {
 		return "", err
 	}
@@ -114,26 +429,34 @@ func (c *ERC721Contract) Transfer(to string, tokenid uint64, wallet wallet.Walle
 	return tx.Hash().Hex(), nil
 }
 
-// approve token to receiver, tokenid 0 means all tokens
-func (c *ERC721Contract) Approve(to string, tokenid uint64, approve bool, wallet wallet.Wallet) (string, error) {
-	opts, err := c.chain.(*chain.EthChain).GenTransOpts(wallet, nil)
+// save token ids of accounts to excel file, one token each row
+func SaveInventoryFile(address string, holdings []*ERC721Holding, path string) error {
+	file, err := excel.NewExcel(path)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	if tokenid == 0 {
-		tx, err := c.contract.SetApprovalForAll(opts, common.HexToAddress(to), approve)
-		if err != nil {
-			return "", err
-		}
+	err = file.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close(true)
 
-		return tx.Hash().Hex(), nil
-	} else {
-		tx, err := c.contract.Approve(opts, common.HexToAddress(to), new(big.Int).SetUint64(tokenid))
-		if err != nil {
-			return "", err
-		}
+	data := make([][]string, 0)
+	data = append(data, INVENTORY_LIST_HEADER)
+
+	// [index, account, contract, tokenid]
+	for _, holding := range holdings {
+		for _, tokenid := range holding.TokenIds {
+			row := make([]string, 0, len(INVENTORY_LIST_HEADER))
+			row = append(row, strconv.Itoa(len(data)))
+			row = append(row, holding.Account)
+			row = append(row, address)
+			row = append(row, tokenid.String())
 
-		return tx.Hash().Hex(), nil
+			data = append(data, row)
+		}
 	}
+
+	return file.WriteAll(INVENTORY_SHEET_NAME, data)
 }
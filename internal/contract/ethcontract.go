@@ -1,6 +1,7 @@
 package contract
 
 import (
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -14,6 +15,7 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
@@ -37,9 +39,9 @@ func (c *EthContract) Address() string {
 	return c.address.Hex()
 }
 
-func (c *EthContract) Code() (string, error) {
+func (c *EthContract) Code(ctx context.Context) (string, error) {
 	// read code from chain
-	return c.chain.Code(c.address.Hex())
+	return c.chain.Code(ctx, c.address.Hex())
 }
 
 func (c *EthContract) ABI() string {
@@ -58,75 +60,67 @@ func (c *EthContract) SetABI(path string) error {
 		return err
 	}
 
+	// record methods for decoding call data later
+	if Selectors != nil {
+		_, err = Selectors.AddABI(&parsed)
+		if err != nil {
+			return err
+		}
+	}
+
 	c.abi = string(data)
-	c.client = bind.NewBoundContract(c.address, parsed, c.chain.(*chain.EthChain).Client, c.chain.(*chain.EthChain).Client, c.chain.(*chain.EthChain).Client)
+	backend := c.chain.(*chain.EthChain).Backend()
+	c.client = bind.NewBoundContract(c.address, parsed, backend, backend, backend)
 
 	return nil
 }
 
-func (c *EthContract) Deploy(code string, params string, wallet wallet.Wallet, value *big.Int) (string, error) {
+func (c *EthContract) Deploy(ctx context.Context, code string, params string, wallet wallet.Wallet, value *big.Int) (string, string, error) {
 	// parse the constructor params
 	method, args, err := helper.ParseParams(params)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	if method != "" {
-		return "", errors.New("method must be empty for constructor")
+		return "", "", errors.New("method must be empty for constructor")
 	}
 
 	// parse abi for get constructor method
 	parsed, err := abi.JSON(strings.NewReader(string(c.abi)))
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	data := make([]interface{}, 0)
-	if len(parsed.Constructor.Inputs) > 0 {
-		index := 0
-		for _, p := range parsed.Constructor.Inputs {
-			if len(args) <= index {
-				return "", errors.New("Not enough parameters")
-			}
-
-			if p.Type.Elem != nil {
-				var subdata interface{}
-				subdata, index, err = helper.Str2Array(args, index, p.Type)
-				if err != nil {
-					return "", err
-				}
-
-				data = append(data, subdata)
-			} else {
-				v, err := helper.Str2Type(args[index], p.Type.GetType())
-				if err != nil {
-					return "", err
-				}
-
-				data = append(data, v)
-				index++
-			}
-		}
+	data, err := parseArgs(parsed.Constructor.Inputs, args)
+	if err != nil {
+		return "", "", err
 	}
 
 	// get transaciton options for sign tx and set value
-	opts, err := c.chain.(*chain.EthChain).GenTransOpts(wallet, value)
+	opts, err := c.chain.(*chain.EthChain).GenTransOpts(ctx, wallet, value)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	// send deploy transaction
-	address, _, _, err := bind.DeployContract(opts, parsed, common.Hex2Bytes(code), c.chain.(*chain.EthChain).Client, data...)
+	// send deploy transaction with local nonce
+	var address common.Address
+	tx, err := c.chain.(*chain.EthChain).Transact(ctx, wallet.Address(), func(nonce uint64) (*types.Transaction, error) {
+		opts.Nonce = new(big.Int).SetUint64(nonce)
+		deployed, tx, _, err := bind.DeployContract(opts, parsed, common.Hex2Bytes(code), c.chain.(*chain.EthChain).Backend(), data...)
+		address = deployed
+		return tx, err
+	})
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	// get contract address
+	// get contract address and transaction hash
 	c.address = address
-	return address.Hex(), nil
+	return address.Hex(), tx.Hash().Hex(), nil
 }
 
-func (c *EthContract) Call(params string, wallet wallet.Wallet, value *big.Int) ([]interface{}, error) {
+func (c *EthContract) Call(ctx context.Context, params string, wallet wallet.Wallet, value *big.Int) (*CallResult, error) {
 	// parse the constructor params
 	method, args, err := helper.ParseParams(params)
 	if err != nil {
@@ -144,60 +138,159 @@ func (c *EthContract) Call(params string, wallet wallet.Wallet, value *big.Int)
 		return nil, errors.New("Can not found methon in abi")
 	}
 
-	index := 0
-	data := make([]interface{}, 0)
-	for _, p := range m.Inputs {
-		if len(args) <= index {
-			return nil, errors.New("Not enough parameters")
-		}
-
-		if p.Type.Elem != nil {
-			var subdata interface{}
-			subdata, index, err = helper.Str2Array(args, index, p.Type)
-			if err != nil {
-				return nil, err
-			}
-
-			data = append(data, subdata)
-		} else {
-			v, err := helper.Str2Type(args[index], p.Type.GetType())
-			if err != nil {
-				return nil, err
-			}
-
-			data = append(data, v)
-			index++
-		}
+	data, err := parseArgs(m.Inputs, args)
+	if err != nil {
+		return nil, err
 	}
 
-	// call contract if method is read-only, otherwise send transaction
-	var result []interface{}
+	// call contract if method is read-only and decode outputs, otherwise send transaction
+	result := &CallResult{Method: m.Sig}
 	if m.IsConstant() {
-		err = c.client.Call(nil, &result, method, data...)
+		var values []interface{}
+		err = c.client.Call(&bind.CallOpts{Context: ctx}, &values, method, data...)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Outputs, err = newOutputs(m.Outputs, values)
 		if err != nil {
 			return nil, err
 		}
 	} else {
 		// get transaciton options for sign tx and set value
-		opts, err := c.chain.(*chain.EthChain).GenTransOpts(wallet, value)
+		opts, err := c.chain.(*chain.EthChain).GenTransOpts(ctx, wallet, value)
 		if err != nil {
 			return nil, err
 		}
 
-		tx, err := c.client.Transact(opts, method, data...)
+		tx, err := c.chain.(*chain.EthChain).Transact(ctx, wallet.Address(), func(nonce uint64) (*types.Transaction, error) {
+			opts.Nonce = new(big.Int).SetUint64(nonce)
+			return c.client.Transact(opts, method, data...)
+		})
 		if err != nil {
 			return nil, err
 		}
 
-		// return transaction hash
-		result = make([]interface{}, 0)
-		result = append(result, tx.Hash().Hex())
+		result.TxHash = tx.Hash().Hex()
 	}
 
-	// todo: parse the call result or wait transction receipt
 	return result, nil
 }
 
+// wait transaction of call result until confirmed and decode the emitted events
+func (c *EthContract) Wait(ctx context.Context, result *CallResult, confirm uint64) error {
+	if result.TxHash == "" {
+		return nil
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(string(c.abi)))
+	if err != nil {
+		return err
+	}
+
+	ethchain, ok := c.chain.(*chain.EthChain)
+	if !ok {
+		return errors.New("Wait only support evm chain")
+	}
+
+	txResult, err := chain.NewTracker(ethchain, confirm).Wait(ctx, result.TxHash)
+	if err != nil {
+		return err
+	}
+
+	result.setTxResult(txResult, &parsed)
+	return nil
+}
+
+// simulate deploy transaction on pending block without sending it
+func (c *EthContract) DryRunDeploy(ctx context.Context, code string, params string, wallet wallet.Wallet, value *big.Int) (*chain.Simulation, error) {
+	method, args, err := helper.ParseParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if method != "" {
+		return nil, errors.New("method must be empty for constructor")
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(string(c.abi)))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := parseArgs(parsed.Constructor.Inputs, args)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := parsed.Pack("", data...)
+	if err != nil {
+		return nil, err
+	}
+
+	return simulate(ctx, c.chain, wallet.Address(), nil, value, append(common.Hex2Bytes(code), input...))
+}
+
+// simulate call transaction on pending block without sending it
+func (c *EthContract) DryRunCall(ctx context.Context, params string, wallet wallet.Wallet, value *big.Int) (*chain.Simulation, error) {
+	method, args, err := helper.ParseParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(string(c.abi)))
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := parsed.Methods[method]
+	if !ok {
+		return nil, errors.New("Can not found methon in abi")
+	}
+
+	data, err := parseArgs(m.Inputs, args)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := parsed.Pack(method, data...)
+	if err != nil {
+		return nil, err
+	}
+
+	return simulate(ctx, c.chain, wallet.Address(), &c.address, value, input)
+}
+
+// check the method of call params is read-only
+func (c *EthContract) IsConstant(params string) bool {
+	method, _, err := helper.ParseParams(params)
+	if err != nil {
+		return false
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(c.abi))
+	if err != nil {
+		return false
+	}
+
+	m, ok := parsed.Methods[method]
+	return ok && m.IsConstant()
+}
+
+// query events with abi set by SetABI
+func (c *EthContract) Events(ctx context.Context, name string, from uint64, to uint64) ([]*Event, error) {
+	if c.abi == "" {
+		return nil, errors.New("Contract abi is not set")
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(c.abi))
+	if err != nil {
+		return nil, err
+	}
+
+	return QueryEvents(ctx, c.chain, c.address.Hex(), &parsed, name, from, to)
+}
+
 func (c *EthContract) EncodeABI(method string, data string, withfunc bool) (string, error) {
 	funcname, argtypes, err := helper.ParseParams(method)
 	if err != nil {
@@ -213,54 +306,21 @@ func (c *EthContract) EncodeABI(method string, data string, withfunc bool) (stri
 		return "", errors.New("Not match call funcion name")
 	}
 
-	// trim space and change to lower case
-	arguments := make(abi.Arguments, 0)
-	for i, arg := range argtypes {
-		argtypes[i] = strings.ToLower(strings.Trim(arg, " "))
-		if argtypes[i] == "uint" || argtypes[i] == "int" {
-			argtypes[i] = argtypes[i] + "256"
-		} else if argtypes[i] == "uint[]" {
-			argtypes[i] = "uint256[]"
-		} else if argtypes[i] == "int[]" {
-			argtypes[i] = "int256[]"
-		}
-
-		argtype, _ := abi.NewType(argtypes[i], "", nil)
-		arguments = append(arguments, abi.Argument{Type: argtype})
+	arguments, err := parseTypes(argtypes)
+	if err != nil {
+		return "", err
 	}
 
 	// get the function signature
 	result := make([]byte, 0)
 	if withfunc {
-		sig := fmt.Sprintf("%v(%v)", funcname, strings.Join(argtypes, ","))
-		result = append(result, crypto.Keccak256([]byte(sig))[:4]...)
+		result = append(result, crypto.Keccak256([]byte(funcSignature(funcname, arguments)))[:4]...)
 	}
 
 	// change string data to dst type
-	index := 0
-	argData := make([]interface{}, 0)
-	for _, p := range arguments {
-		if len(args) <= index {
-			return "", errors.New("Not enough parameters")
-		}
-
-		if p.Type.Elem != nil {
-			var subdata interface{}
-			subdata, index, err = helper.Str2Array(args, index, p.Type)
-			if err != nil {
-				return "", err
-			}
-
-			argData = append(argData, subdata)
-		} else {
-			v, err := helper.Str2Type(args[index], p.Type.GetType())
-			if err != nil {
-				return "", err
-			}
-
-			argData = append(argData, v)
-			index++
-		}
+	argData, err := parseArgs(arguments, args)
+	if err != nil {
+		return "", err
 	}
 
 	// pack all parameters
@@ -274,7 +334,7 @@ func (c *EthContract) EncodeABI(method string, data string, withfunc bool) (stri
 }
 
 func (c *EthContract) DecodeABI(method string, data string, withfunc bool) (string, error) {
-	rdata := common.Hex2Bytes(data)
+	rdata := common.FromHex(data)
 
 	// parse the function sig
 	funcname, argtypes, err := helper.ParseParams(method)
@@ -282,27 +342,15 @@ func (c *EthContract) DecodeABI(method string, data string, withfunc bool) (stri
 		return "", err
 	}
 
-	// trim space and change to lower case
-	arguments := make(abi.Arguments, 0)
-	for i, arg := range argtypes {
-		argtypes[i] = strings.ToLower(strings.Trim(arg, " "))
-		if argtypes[i] == "uint" || argtypes[i] == "int" {
-			argtypes[i] = argtypes[i] + "256"
-		} else if argtypes[i] == "uint[]" {
-			argtypes[i] = "uint256[]"
-		} else if argtypes[i] == "int[]" {
-			argtypes[i] = "int256[]"
-		}
-
-		argtype, _ := abi.NewType(argtypes[i], "", nil)
-		arguments = append(arguments, abi.Argument{Type: argtype})
+	arguments, err := parseTypes(argtypes)
+	if err != nil {
+		return "", err
 	}
 
 	// check the method signature
 	if withfunc {
-		sig := fmt.Sprintf("%v(%v)", funcname, strings.Join(argtypes, ","))
-		funcsig := crypto.Keccak256([]byte(sig))[:4]
-		if hex.EncodeToString(funcsig) != hex.EncodeToString(rdata[:4]) {
+		funcsig := crypto.Keccak256([]byte(funcSignature(funcname, arguments)))[:4]
+		if len(rdata) < 4 || hex.EncodeToString(funcsig) != hex.EncodeToString(rdata[:4]) {
 			return "", errors.New("Not match function type")
 		}
 
@@ -315,33 +363,59 @@ func (c *EthContract) DecodeABI(method string, data string, withfunc bool) (stri
 		return "", err
 	}
 
-	// change result to string
-	var builder strings.Builder
-	builder.WriteString(funcname)
-	builder.WriteString("(")
+	return formatCall(funcname, arguments, parsed)
+}
 
-	for index, p := range arguments {
-		if p.Type.Elem != nil {
-			subdata, err := helper.Array2Str(parsed[index], p.Type.Elem.GetType())
-			if err != nil {
-				return "", err
-			}
+// change decoded arguments to call string, like transfer(0x01,100)
+func formatCall(funcname string, arguments abi.Arguments, values []interface{}) (string, error) {
+	outputs, err := helper.Outputs2Str(arguments, values)
+	if err != nil {
+		return "", err
+	}
 
-			builder.WriteString(subdata)
-		} else {
-			v, err := helper.Type2Str(parsed[index], p.Type.GetType())
-			if err != nil {
-				return "", err
-			}
+	return fmt.Sprintf("%s(%s)", funcname, strings.Join(outputs, ",")), nil
+}
 
-			builder.WriteString(v)
+// parse argument types of function sig
+func parseTypes(argtypes []string) (abi.Arguments, error) {
+	arguments := make(abi.Arguments, 0, len(argtypes))
+	for _, arg := range argtypes {
+		argtype, err := helper.ParseType(arg)
+		if err != nil {
+			return nil, err
 		}
 
-		if index != len(arguments)-1 {
-			builder.WriteString(",")
+		arguments = append(arguments, abi.Argument{Type: argtype})
+	}
+
+	return arguments, nil
+}
+
+// canonical function signature for selector
+func funcSignature(funcname string, arguments abi.Arguments) string {
+	items := make([]string, 0, len(arguments))
+	for _, argument := range arguments {
+		items = append(items, argument.Type.String())
+	}
+
+	return fmt.Sprintf("%v(%v)", funcname, strings.Join(items, ","))
+}
+
+// change string arguments to abi types
+func parseArgs(inputs abi.Arguments, args []string) ([]interface{}, error) {
+	if len(args) != len(inputs) {
+		return nil, fmt.Errorf("Need %d parameters but %d", len(inputs), len(args))
+	}
+
+	data := make([]interface{}, 0, len(inputs))
+	for i, p := range inputs {
+		v, err := helper.Str2Value(args[i], p.Type)
+		if err != nil {
+			return nil, err
 		}
+
+		data = append(data, v)
 	}
 
-	builder.WriteString(")")
-	return builder.String(), nil
+	return data, nil
 }
@@ -0,0 +1,186 @@
+package contract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"utopia/internal/chain"
+	"utopia/internal/excel"
+	"utopia/internal/helper"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// name of event not found in abi
+const UNKNOWN_EVENT = "unknown"
+
+var (
+	EVENT_SHEET_NAME  = "events"
+	EVENT_LIST_HEADER = []string{"index", "block", "transaction", "logindex", "address", "event", "args"}
+)
+
+// decoded event log
+type Event struct {
+	Name        string                 // Event name
+	Address     string                 // Contract address which emitted the event
+	BlockNumber uint64                 // Block number of log
+	TxHash      string                 // Transaction hash of log
+	LogIndex    uint                   // Index of log in block
+	Inputs      abi.Arguments          // Event arguments in order
+	Args        map[string]interface{} // Decoded argument values
+	Log         types.Log              // Raw log
+}
+
+// print arguments in abi order
+func (e *Event) ArgsText() string {
+	items := make([]string, 0, len(e.Args))
+	if e.Name == UNKNOWN_EVENT {
+		for _, topic := range e.Log.Topics {
+			items = append(items, topic.Hex())
+		}
+		items = append(items, hexutil.Encode(e.Log.Data))
+		return strings.Join(items, ",")
+	}
+
+	// format same as call result
+	for _, input := range e.Inputs {
+		text, err := helper.Abi2Str(e.Args[input.Name], input.Type)
+		if err != nil {
+			text = fmt.Sprintf("%v", e.Args[input.Name])
+		}
+
+		items = append(items, fmt.Sprintf("%s=%s", input.Name, text))
+	}
+
+	return strings.Join(items, ",")
+}
+
+func (e *Event) String() string {
+	return fmt.Sprintf("[%d][%s][%d] %s %s(%s)", e.BlockNumber, e.TxHash, e.LogIndex, e.Address, e.Name, e.ArgsText())
+}
+
+// decode log by event in abi, the event not in abi or failed to decode is unknown event
+func DecodeLog(parsed *abi.ABI, log types.Log) (*Event, error) {
+	e := &Event{
+		Name:        UNKNOWN_EVENT,
+		Address:     log.Address.Hex(),
+		BlockNumber: log.BlockNumber,
+		TxHash:      log.TxHash.Hex(),
+		LogIndex:    log.Index,
+		Args:        make(map[string]interface{}),
+		Log:         log,
+	}
+
+	if len(log.Topics) == 0 {
+		return e, nil
+	}
+
+	event, err := parsed.EventByID(log.Topics[0])
+	if err != nil {
+		return e, nil
+	}
+
+	// indexed arguments in topics, others in data
+	indexed := make(abi.Arguments, 0)
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+
+	err = abi.ParseTopicsIntoMap(e.Args, indexed, log.Topics[1:])
+	if err != nil {
+		return e, err
+	}
+
+	if len(log.Data) > 0 {
+		err = event.Inputs.UnpackIntoMap(e.Args, log.Data)
+		if err != nil {
+			return e, err
+		}
+	}
+
+	e.Name = event.Name
+	e.Inputs = event.Inputs
+	return e, nil
+}
+
+// query and decode events of contract, empty name for all events, to is 0 for latest block
+func QueryEvents(ctx context.Context, c chain.Chain, address string, parsed *abi.ABI, name string, from uint64, to uint64) ([]*Event, error) {
+	evm, ok := c.(chain.EvmChain)
+	if !ok {
+		return nil, errors.New("Events only support evm chain")
+	}
+
+	query := ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		Addresses: []common.Address{common.HexToAddress(address)},
+	}
+
+	if to != 0 {
+		query.ToBlock = new(big.Int).SetUint64(to)
+	}
+
+	if name != "" {
+		event, ok := parsed.Events[name]
+		if !ok {
+			return nil, fmt.Errorf("Event %s is not exist", name)
+		}
+
+		query.Topics = [][]common.Hash{{event.ID}}
+	}
+
+	logs, err := evm.FilterLogs(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*Event, 0, len(logs))
+	for _, log := range logs {
+		// keep the log failed to decode as unknown event
+		e, _ := DecodeLog(parsed, log)
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// save events to excel file
+func SaveEventFile(events []*Event, path string) error {
+	file, err := excel.NewExcel(path)
+	if err != nil {
+		return err
+	}
+
+	err = file.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close(true)
+
+	data := make([][]string, 0, len(events)+1)
+	data = append(data, EVENT_LIST_HEADER)
+
+	// [index, block, transaction, logindex, address, event, args]
+	for i, e := range events {
+		row := make([]string, 0, len(EVENT_LIST_HEADER))
+		row = append(row, strconv.Itoa(i+1))
+		row = append(row, strconv.FormatUint(e.BlockNumber, 10))
+		row = append(row, e.TxHash)
+		row = append(row, strconv.FormatUint(uint64(e.LogIndex), 10))
+		row = append(row, e.Address)
+		row = append(row, e.Name)
+		row = append(row, helper.DefaultVlue(e.ArgsText(), "x"))
+
+		data = append(data, row)
+	}
+
+	return file.WriteAll(EVENT_SHEET_NAME, data)
+}
@@ -0,0 +1,296 @@
+package contract
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"utopia/internal/database"
+	"utopia/internal/excel"
+	utopia_network "utopia/internal/network"
+)
+
+const (
+	// timeout of each gateway request
+	METADATA_GATEWAY_TIMEOUT = 10 * time.Second
+)
+
+var (
+	DEFAULT_IPFS_GATEWAYS    = []string{"https://ipfs.io/ipfs/", "https://cloudflare-ipfs.com/ipfs/", "https://gateway.pinata.cloud/ipfs/"}
+	DEFAULT_ARWEAVE_GATEWAYS = []string{"https://arweave.net/"}
+
+	TRAIT_SHEET_NAME   = "traits"
+	TRAIT_LIST_HEADER  = []string{"index", "tokenid", "name", "score", "rank", "attributes"}
+	RARITY_SHEET_NAME  = "rarity"
+	RARITY_LIST_HEADER = []string{"trait_type", "value", "count", "frequency"}
+)
+
+// resolver of token metadata uri, the fetched metadata is cached in database
+type MetadataResolver struct {
+	db              *database.Database // Cache database, nil if not cached
+	IpfsGateways    []string           // Gateways tried in order for ipfs://
+	ArweaveGateways []string           // Gateways tried in order for ar://
+	Timeout         time.Duration      // Timeout of each gateway request
+}
+
+// metadata and rarity of token in collection
+type TokenTraits struct {
+	TokenId string
+	Meta    *ERC721Meta
+	Score   float64 // Sum of inverse frequency of each trait
+	Rank    int     // 1 is the rarest
+}
+
+// create resolver with opened database as cache, db can be nil
+func NewMetadataResolver(db *database.Database, ipfs []string, arweave []string) (*MetadataResolver, error) {
+	if db != nil {
+		_, err := db.ExecSql("create table if not exists nft_metadata(uri text primary key, data text, updated integer);")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(ipfs) == 0 {
+		ipfs = DEFAULT_IPFS_GATEWAYS
+	}
+
+	if len(arweave) == 0 {
+		arweave = DEFAULT_ARWEAVE_GATEWAYS
+	}
+
+	return &MetadataResolver{
+		db:              db,
+		IpfsGateways:    ipfs,
+		ArweaveGateways: arweave,
+		Timeout:         METADATA_GATEWAY_TIMEOUT,
+	}, nil
+}
+
+// resolve uri and parse metadata
+func (r *MetadataResolver) Resolve(ctx context.Context, uri string) (*ERC721Meta, error) {
+	data, err := r.Fetch(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &ERC721Meta{}
+	err = json.Unmarshal(data, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// fetch content of uri from cache or gateways, the data uri is decoded directly
+func (r *MetadataResolver) Fetch(ctx context.Context, uri string) ([]byte, error) {
+	uri = strings.TrimSpace(uri)
+	if strings.HasPrefix(uri, "data:") {
+		return decodeDataUri(uri)
+	}
+
+	if r.db != nil {
+		rows, err := r.db.Query("select data from nft_metadata where uri = ?;", uri)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(rows) > 0 {
+			return []byte(rows[0][0].(string)), nil
+		}
+	}
+
+	urls, err := r.HttpUrls(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	// try gateways in order until success, the hung gateway is skipped after timeout
+	var data []byte
+	for _, u := range urls {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		gctx, cancel := context.WithTimeout(ctx, r.Timeout)
+		data, err = utopia_network.HttpGetContext(gctx, u, nil)
+		cancel()
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Fetch %s failed with err: %v", uri, err)
+	}
+
+	if r.db != nil {
+		_, err = r.db.ExecSql("insert or replace into nft_metadata(uri, data, updated) values(?, ?, ?);", uri, string(data), time.Now().Unix())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// http urls of uri through gateways
+func (r *MetadataResolver) HttpUrls(uri string) ([]string, error) {
+	var gateways []string
+	var path string
+	switch {
+	case strings.HasPrefix(uri, "ipfs://"):
+		// ipfs://cid/path or ipfs://ipfs/cid/path
+		path = strings.TrimPrefix(strings.TrimPrefix(uri, "ipfs://"), "ipfs/")
+		gateways = r.IpfsGateways
+	case strings.HasPrefix(uri, "ar://"):
+		path = strings.TrimPrefix(uri, "ar://")
+		gateways = r.ArweaveGateways
+	case strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://"):
+		return []string{uri}, nil
+	default:
+		return nil, errors.New("Not support uri " + uri)
+	}
+
+	urls := make([]string, 0, len(gateways))
+	for _, gateway := range gateways {
+		urls = append(urls, strings.TrimSuffix(gateway, "/")+"/"+path)
+	}
+
+	return urls, nil
+}
+
+// decode data:application/json;base64,xxx or data:application/json,xxx
+func decodeDataUri(uri string) ([]byte, error) {
+	index := strings.Index(uri, ",")
+	if index == -1 {
+		return nil, errors.New("Invalid data uri")
+	}
+
+	header, content := uri[:index], uri[index+1:]
+	if strings.HasSuffix(header, ";base64") {
+		return base64.StdEncoding.DecodeString(content)
+	}
+
+	data, err := url.PathUnescape(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(data), nil
+}
+
+// trait value in string
+func (a *ERC721Attr) ValueText() string {
+	switch v := a.Value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// count each trait value and score tokens by sum of inverse trait frequency, the tokens are sorted by rank
+func RarityScores(tokens []*TokenTraits) map[string]map[string]int {
+	counts := make(map[string]map[string]int)
+	for _, token := range tokens {
+		for _, attr := range token.Meta.Attributes {
+			if counts[attr.TraitType] == nil {
+				counts[attr.TraitType] = make(map[string]int)
+			}
+
+			counts[attr.TraitType][attr.ValueText()]++
+		}
+	}
+
+	total := float64(len(tokens))
+	for _, token := range tokens {
+		token.Score = 0
+		for _, attr := range token.Meta.Attributes {
+			token.Score += total / float64(counts[attr.TraitType][attr.ValueText()])
+		}
+	}
+
+	sort.SliceStable(tokens, func(i, j int) bool {
+		return tokens[i].Score > tokens[j].Score
+	})
+
+	for i, token := range tokens {
+		token.Rank = i + 1
+	}
+
+	return counts
+}
+
+// save token traits with rarity and count of each trait value to excel file
+func SaveTraitsFile(tokens []*TokenTraits, counts map[string]map[string]int, path string) error {
+	file, err := excel.NewExcel(path)
+	if err != nil {
+		return err
+	}
+
+	err = file.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close(true)
+
+	// [index, tokenid, name, score, rank, attributes]
+	data := make([][]string, 0, len(tokens)+1)
+	data = append(data, TRAIT_LIST_HEADER)
+	for i, token := range tokens {
+		attrs := make([]string, 0, len(token.Meta.Attributes))
+		for _, attr := range token.Meta.Attributes {
+			attrs = append(attrs, fmt.Sprintf("%s=%s", attr.TraitType, attr.ValueText()))
+		}
+
+		row := make([]string, 0, len(TRAIT_LIST_HEADER))
+		row = append(row, strconv.Itoa(i+1))
+		row = append(row, token.TokenId)
+		row = append(row, token.Meta.Name)
+		row = append(row, strconv.FormatFloat(token.Score, 'f', 2, 64))
+		row = append(row, strconv.Itoa(token.Rank))
+		row = append(row, strings.Join(attrs, ";"))
+
+		data = append(data, row)
+	}
+
+	err = file.WriteAll(TRAIT_SHEET_NAME, data)
+	if err != nil {
+		return err
+	}
+
+	// [trait_type, value, count, frequency] sorted by trait and value
+	traits := make([]string, 0, len(counts))
+	for trait := range counts {
+		traits = append(traits, trait)
+	}
+	sort.Strings(traits)
+
+	data = make([][]string, 0)
+	data = append(data, RARITY_LIST_HEADER)
+	for _, trait := range traits {
+		values := make([]string, 0, len(counts[trait]))
+		for value := range counts[trait] {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+
+		for _, value := range values {
+			count := counts[trait][value]
+			data = append(data, []string{trait, value, strconv.Itoa(count), strconv.FormatFloat(float64(count)/float64(len(tokens)), 'f', 4, 64)})
+		}
+	}
+
+	return file.WriteAll(RARITY_SHEET_NAME, data)
+}
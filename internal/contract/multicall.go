@@ -0,0 +1,267 @@
+package contract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"utopia/internal/chain"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	MULTICALL3_ADDRESS   = "0xcA11bde05977b3631167028862bE2a173976CA11" // Canonical Multicall3 address on most chains
+	MULTICALL_BATCH_SIZE = 100                                          // Max calls in one aggregate call
+)
+
+const (
+	multicall3ABI = `[{"inputs":[{"components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}],"name":"calls","type":"tuple[]"}],"name":"aggregate3","outputs":[{"components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}],"name":"returnData","type":"tuple[]"}],"stateMutability":"payable","type":"function"}]`
+	muticallABI   = `[{"inputs":[{"name":"targets","type":"address[]"},{"name":"data","type":"bytes[]"}],"name":"multiCall","outputs":[{"name":"","type":"bytes[]"}],"stateMutability":"nonpayable","type":"function"}]`
+)
+
+// read call of contract method
+type MultiCall struct {
+	Target string        // Contract address
+	ABI    *abi.ABI      // Contract ABI to encode args and decode result
+	Method string        // Method name
+	Args   []interface{} // Method arguments
+}
+
+// result of each read call
+type MultiResult struct {
+	Success bool          // Is call success
+	Values  []interface{} // Decoded outputs of method
+	Err     error         // Error of failed call
+}
+
+// multicall client aggregates read calls into one eth_call
+type Multicall struct {
+	chain     *chain.EthChain
+	address   common.Address // Address of multicall contract
+	muticall  bool           // Is the bundled MutiCall contract
+	abi       abi.ABI        // ABI of multicall contract
+	BatchSize int            // Max calls in one aggregate call
+}
+
+type call3 struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type result3 struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// create multicall client by the contract configured in chain meta
+func NewMulticall(c chain.Chain) (*Multicall, error) {
+	ethchain, ok := c.(*chain.EthChain)
+	if !ok {
+		return nil, errors.New("Multicall only support evm chain")
+	}
+
+	meta, err := chain.ChainMetaById(ethchain.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if meta.Multicall == "" {
+		return nil, errors.New("Multicall contract is not configured")
+	}
+
+	return NewMulticallAt(ethchain, meta.Multicall, meta.MulticallBundled)
+}
+
+// create multicall client with contract address, muticall is true for the bundled MutiCall contract
+func NewMulticallAt(c *chain.EthChain, address string, muticall bool) (*Multicall, error) {
+	data := multicall3ABI
+	if muticall {
+		data = muticallABI
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Multicall{
+		chain:     c,
+		address:   common.HexToAddress(address),
+		muticall:  muticall,
+		abi:       parsed,
+		BatchSize: MULTICALL_BATCH_SIZE,
+	}, nil
+}
+
+// aggregate read calls, failed call only marks its own result
+func (m *Multicall) Call(ctx context.Context, calls []*MultiCall) ([]*MultiResult, error) {
+	results := make([]*MultiResult, len(calls))
+
+	// encode the call data, the invalid call is failed without sending
+	data := make([][]byte, len(calls))
+	for i, call := range calls {
+		input, err := call.ABI.Pack(call.Method, call.Args...)
+		if err != nil {
+			results[i] = &MultiResult{Err: err}
+			continue
+		}
+
+		data[i] = input
+	}
+
+	size := m.BatchSize
+	if size <= 0 {
+		size = MULTICALL_BATCH_SIZE
+	}
+
+	for start := 0; start < len(calls); start += size {
+		end := start + size
+		if end > len(calls) {
+			end = len(calls)
+		}
+
+		err := m.aggregate(ctx, calls[start:end], data[start:end], results[start:end])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+func (m *Multicall) aggregate(ctx context.Context, calls []*MultiCall, data [][]byte, results []*MultiResult) error {
+	// index of calls to send
+	index := make([]int, 0, len(calls))
+	for i := range calls {
+		if results[i] == nil {
+			index = append(index, i)
+		}
+	}
+
+	if len(index) == 0 {
+		return nil
+	}
+
+	if m.muticall {
+		return m.muticallAggregate(ctx, calls, data, results, index)
+	}
+
+	input := make([]call3, 0, len(index))
+	for _, i := range index {
+		input = append(input, call3{Target: common.HexToAddress(calls[i].Target), AllowFailure: true, CallData: data[i]})
+	}
+
+	output, err := m.call(ctx, "aggregate3", input)
+	if err != nil {
+		return err
+	}
+
+	var returns []result3
+	err = m.abi.UnpackIntoInterface(&returns, "aggregate3", output)
+	if err != nil {
+		return err
+	}
+
+	if len(returns) != len(index) {
+		return errors.New("Not match length of multicall result")
+	}
+
+	for n, i := range index {
+		if !returns[n].Success {
+			results[i] = &MultiResult{Err: revertError(returns[n].ReturnData)}
+			continue
+		}
+
+		results[i] = decodeResult(calls[i], returns[n].ReturnData)
+	}
+
+	return nil
+}
+
+// the bundled contract reverts if any call failed, so send calls one by one to find the failed
+func (m *Multicall) muticallAggregate(ctx context.Context, calls []*MultiCall, data [][]byte, results []*MultiResult, index []int) error {
+	targets := make([]common.Address, 0, len(index))
+	input := make([][]byte, 0, len(index))
+	for _, i := range index {
+		targets = append(targets, common.HexToAddress(calls[i].Target))
+		input = append(input, data[i])
+	}
+
+	output, err := m.call(ctx, "multiCall", targets, input)
+	if err == nil {
+		var returns [][]byte
+		err = m.abi.UnpackIntoInterface(&returns, "multiCall", output)
+		if err != nil {
+			return err
+		}
+
+		if len(returns) != len(index) {
+			return errors.New("Not match length of multicall result")
+		}
+
+		for n, i := range index {
+			results[i] = decodeResult(calls[i], returns[n])
+		}
+
+		return nil
+	}
+
+	// only execution error of node falls back, the transport error fails all
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return err
+	}
+
+	backend := m.chain.Backend()
+	for _, i := range index {
+		to := common.HexToAddress(calls[i].Target)
+		output, err := backend.CallContract(ctx, ethereum.CallMsg{To: &to, Data: data[i]}, nil)
+		if err != nil {
+			results[i] = &MultiResult{Err: errors.New(chain.RevertReason(err))}
+			continue
+		}
+
+		results[i] = decodeResult(calls[i], output)
+	}
+
+	return nil
+}
+
+// eth_call to multicall contract
+func (m *Multicall) call(ctx context.Context, method string, args ...interface{}) ([]byte, error) {
+	input, err := m.abi.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.chain.Backend().CallContract(ctx, ethereum.CallMsg{To: &m.address, Data: input}, nil)
+}
+
+// decode return data by method outputs, empty data means the target is not contract
+func decodeResult(call *MultiCall, data []byte) *MultiResult {
+	method, ok := call.ABI.Methods[call.Method]
+	if ok && len(data) == 0 && len(method.Outputs) > 0 {
+		return &MultiResult{Err: errors.New("Empty return data")}
+	}
+
+	values, err := call.ABI.Unpack(call.Method, data)
+	if err != nil {
+		return &MultiResult{Err: err}
+	}
+
+	return &MultiResult{Success: true, Values: values}
+}
+
+func revertError(data []byte) error {
+	reason, err := abi.UnpackRevert(data)
+	if err != nil {
+		return fmt.Errorf("execution reverted")
+	}
+
+	return fmt.Errorf("execution reverted: %s", reason)
+}
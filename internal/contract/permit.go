@@ -0,0 +1,210 @@
+package contract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+	"utopia/contracts/token"
+	"utopia/internal/chain"
+	"utopia/internal/wallet"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// EIP-2612 type hash of permit struct
+	PERMIT_TYPEHASH = crypto.Keccak256Hash([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)"))
+)
+
+// signed EIP-2612 permit, it can be submitted by any relayer before deadline
+type ERC20Permit struct {
+	Token     string   `json:"token"`
+	Owner     string   `json:"owner"`
+	Spender   string   `json:"spender"`
+	Value     *big.Int `json:"value"`
+	Nonce     *big.Int `json:"nonce"`
+	Deadline  *big.Int `json:"deadline"`  // Unix timestamp in seconds
+	Signature string   `json:"signature"` // Hex of r, s and v
+}
+
+// query EIP-712 domain separator of token
+func (c *ERC20Contract) DomainSeparator(ctx context.Context) (common.Hash, error) {
+	if c.contract == nil {
+		return common.Hash{}, errors.New("Token only support evm chain")
+	}
+
+	parsed, err := token.ERC20PermitMetaData.GetAbi()
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	result, err := tokenCall(ctx, c.chain, parsed, c.address, "DOMAIN_SEPARATOR")
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return common.Hash(result.([32]byte)), nil
+}
+
+// query permit nonce of owner
+func (c *ERC20Contract) PermitNonce(ctx context.Context, owner string) (*big.Int, error) {
+	if c.contract == nil {
+		return nil, errors.New("Token only support evm chain")
+	}
+
+	parsed, err := token.ERC20PermitMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tokenCall(ctx, c.chain, parsed, c.address, "nonces", common.HexToAddress(owner))
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*big.Int), nil
+}
+
+// EIP-712 digest of permit signed by owner
+func (c *ERC20Contract) PermitDigest(ctx context.Context, permit *ERC20Permit) ([]byte, error) {
+	domain, err := c.DomainSeparator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	structHash := crypto.Keccak256(
+		PERMIT_TYPEHASH.Bytes(),
+		common.HexToAddress(permit.Owner).Hash().Bytes(),
+		common.HexToAddress(permit.Spender).Hash().Bytes(),
+		common.BigToHash(permit.Value).Bytes(),
+		common.BigToHash(permit.Nonce).Bytes(),
+		common.BigToHash(permit.Deadline).Bytes(),
+	)
+
+	return crypto.Keccak256([]byte("\x19\x01"), domain.Bytes(), structHash), nil
+}
+
+// sign permit of value to spender by owner wallet with current nonce, no transaction is sent
+func (c *ERC20Contract) SignPermit(ctx context.Context, spender string, value *big.Int, deadline *big.Int, wallet wallet.Wallet) (*ERC20Permit, error) {
+	nonce, err := c.PermitNonce(ctx, wallet.Address())
+	if err != nil {
+		return nil, err
+	}
+
+	permit := &ERC20Permit{
+		Token:    c.address.Hex(),
+		Owner:    common.HexToAddress(wallet.Address()).Hex(),
+		Spender:  common.HexToAddress(spender).Hex(),
+		Value:    value,
+		Nonce:    nonce,
+		Deadline: deadline,
+	}
+
+	digest, err := c.PermitDigest(ctx, permit)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := crypto.ToECDSA(common.FromHex(wallet.PrivateKey()))
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := crypto.Sign(digest, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// v is 27 or 28 in solidity ecrecover
+	sig[64] += 27
+	permit.Signature = hexutil.Encode(sig)
+	return permit, nil
+}
+
+// verify permit is signed by owner for this token, not expired and not used
+func (c *ERC20Contract) VerifyPermit(ctx context.Context, permit *ERC20Permit) error {
+	if common.HexToAddress(permit.Token) != c.address {
+		return fmt.Errorf("Permit is signed for token %s", permit.Token)
+	}
+
+	if permit.Value == nil || permit.Nonce == nil || permit.Deadline == nil {
+		return errors.New("Incomplete permit")
+	}
+
+	if permit.Deadline.Cmp(big.NewInt(time.Now().Unix())) < 0 {
+		return errors.New("Permit is expired")
+	}
+
+	nonce, err := c.PermitNonce(ctx, permit.Owner)
+	if err != nil {
+		return err
+	}
+
+	if nonce.Cmp(permit.Nonce) != 0 {
+		return fmt.Errorf("Permit nonce %s not match current nonce %s", permit.Nonce.String(), nonce.String())
+	}
+
+	digest, err := c.PermitDigest(ctx, permit)
+	if err != nil {
+		return err
+	}
+
+	sig := common.FromHex(permit.Signature)
+	if len(sig) != crypto.SignatureLength || sig[64] < 27 {
+		return errors.New("Invalid permit signature")
+	}
+
+	sig = append([]byte{}, sig...)
+	sig[64] -= 27
+	pubkey, err := crypto.SigToPub(digest, sig)
+	if err != nil {
+		return err
+	}
+
+	if crypto.PubkeyToAddress(*pubkey) != common.HexToAddress(permit.Owner) {
+		return errors.New("Permit is not signed by owner " + permit.Owner)
+	}
+
+	return nil
+}
+
+// verify permit and submit it by relayer wallet, the relayer pays gas instead of owner
+func (c *ERC20Contract) Permit(ctx context.Context, permit *ERC20Permit, relayer wallet.Wallet) (string, error) {
+	err := c.VerifyPermit(ctx, permit)
+	if err != nil {
+		return "", err
+	}
+
+	parsed, err := token.ERC20PermitMetaData.GetAbi()
+	if err != nil {
+		return "", err
+	}
+
+	var r, s [32]byte
+	sig := common.FromHex(permit.Signature)
+	copy(r[:], sig[:32])
+	copy(s[:], sig[32:64])
+
+	opts, err := c.chain.(*chain.EthChain).GenTransOpts(ctx, relayer, nil)
+	if err != nil {
+		return "", err
+	}
+
+	backend := c.chain.(*chain.EthChain).Backend()
+	bound := bind.NewBoundContract(c.address, *parsed, backend, backend, backend)
+	tx, err := c.chain.(*chain.EthChain).Transact(ctx, relayer.Address(), func(nonce uint64) (*types.Transaction, error) {
+		opts.Nonce = new(big.Int).SetUint64(nonce)
+		return bound.Transact(opts, "permit", common.HexToAddress(permit.Owner), common.HexToAddress(permit.Spender), permit.Value, permit.Deadline, sig[64], r, s)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return tx.Hash().Hex(), nil
+}
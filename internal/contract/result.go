@@ -0,0 +1,154 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"utopia/internal/chain"
+	"utopia/internal/helper"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// formatted value of abi argument
+type CallOutput struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// event emitted by transaction
+type CallEvent struct {
+	Name     string        `json:"name"`
+	Address  string        `json:"address"`
+	LogIndex uint          `json:"logIndex"`
+	Args     []*CallOutput `json:"args"`
+}
+
+// result of contract call, read-only method has outputs, otherwise transaction hash and receipt after wait
+type CallResult struct {
+	Method      string        `json:"method"`
+	Outputs     []*CallOutput `json:"outputs,omitempty"`
+	TxHash      string        `json:"txHash,omitempty"`
+	Status      string        `json:"status,omitempty"`
+	BlockNumber uint64        `json:"blockNumber,omitempty"`
+	GasUsed     uint64        `json:"gasUsed,omitempty"`
+	Fee         string        `json:"fee,omitempty"`
+	Reason      string        `json:"reason,omitempty"`
+	Events      []*CallEvent  `json:"events,omitempty"`
+}
+
+func (r *CallResult) String() string {
+	var builder strings.Builder
+	builder.WriteString(r.Method)
+	if r.TxHash == "" {
+		items := make([]string, 0, len(r.Outputs))
+		for _, output := range r.Outputs {
+			items = append(items, output.String())
+		}
+
+		builder.WriteString(fmt.Sprintf(" returns (%s)", strings.Join(items, ",")))
+		return builder.String()
+	}
+
+	builder.WriteString(fmt.Sprintf(" transaction %s", r.TxHash))
+	if r.Status != "" {
+		builder.WriteString(fmt.Sprintf(" status=%s, block=%d, gasused=%d, fee=%s", r.Status, r.BlockNumber, r.GasUsed, r.Fee))
+	}
+
+	if r.Reason != "" {
+		builder.WriteString(fmt.Sprintf(", reason=%s", r.Reason))
+	}
+
+	for _, event := range r.Events {
+		builder.WriteString("\n  ")
+		builder.WriteString(event.String())
+	}
+
+	return builder.String()
+}
+
+// result in indented json
+func (r *CallResult) JSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func (o *CallOutput) String() string {
+	if o.Name == "" {
+		return fmt.Sprintf("%s %s", o.Type, o.Value)
+	}
+
+	return fmt.Sprintf("%s %s=%s", o.Type, o.Name, o.Value)
+}
+
+func (e *CallEvent) String() string {
+	items := make([]string, 0, len(e.Args))
+	for _, arg := range e.Args {
+		items = append(items, arg.String())
+	}
+
+	return fmt.Sprintf("[%d] %s %s(%s)", e.LogIndex, e.Address, e.Name, strings.Join(items, ","))
+}
+
+// format unpacked values with names and types of abi arguments
+func newOutputs(arguments abi.Arguments, values []interface{}) ([]*CallOutput, error) {
+	texts, err := helper.Outputs2Str(arguments, values)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make([]*CallOutput, 0, len(texts))
+	for i, text := range texts {
+		outputs = append(outputs, &CallOutput{Name: arguments[i].Name, Type: arguments[i].Type.String(), Value: text})
+	}
+
+	return outputs, nil
+}
+
+// decode logs of receipt by abi, the log not in abi is unknown event with raw topics and data
+func newEvents(parsed *abi.ABI, logs []*types.Log) []*CallEvent {
+	events := make([]*CallEvent, 0, len(logs))
+	for _, log := range logs {
+		e, err := DecodeLog(parsed, *log)
+
+		event := &CallEvent{Name: e.Name, Address: e.Address, LogIndex: e.LogIndex}
+		if err == nil && e.Name != UNKNOWN_EVENT {
+			values := make([]interface{}, 0, len(e.Inputs))
+			for _, input := range e.Inputs {
+				values = append(values, e.Args[input.Name])
+			}
+
+			event.Args, err = newOutputs(e.Inputs, values)
+		}
+
+		if err != nil || e.Name == UNKNOWN_EVENT {
+			event.Name = UNKNOWN_EVENT
+			event.Args = []*CallOutput{{Name: "log", Type: "raw", Value: e.ArgsText()}}
+		}
+
+		events = append(events, event)
+	}
+
+	return events
+}
+
+// fill transaction result of tracker
+func (r *CallResult) setTxResult(result *chain.TxResult, parsed *abi.ABI) {
+	r.Status = result.StatusText()
+	r.BlockNumber = result.BlockNumber
+	r.GasUsed = result.GasUsed
+	r.Reason = result.Reason
+	if result.Fee != nil {
+		r.Fee = result.Fee.String()
+	}
+
+	if result.Receipt != nil {
+		r.Events = newEvents(parsed, result.Receipt.Logs)
+	}
+}
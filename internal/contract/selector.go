@@ -0,0 +1,236 @@
+package contract
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	testcontract "utopia/contracts/test"
+	"utopia/contracts/token"
+	"utopia/internal/database"
+	"utopia/internal/helper"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// selector database used by SetABI to record methods, nil if not opened
+	Selectors *SelectorDB
+
+	// abis of contracts in repository
+	builtinABIs = []string{token.ERC20MetaData.ABI, token.ERC20PermitMetaData.ABI, token.ERC721MetaData.ABI, token.ERC1155MetaData.ABI, testcontract.SimpleMetaData.ABI}
+
+	// functions of utility contracts and token extensions without abi file
+	builtinSignatures = []string{
+		"multiCall(address[],bytes[])",
+		"getBalance()",
+		"batchTransfer(address[],uint256[])",
+		"batchTransferToken(address,address[],uint256[])",
+		"withdraw(address,address,uint256,bytes,bytes)",
+		"discard(address,address,uint256,bytes,bytes)",
+		"launch(uint256,uint256,uint256)",
+		"cancel(uint256)",
+		"pledge(uint256,uint256)",
+		"unpledge(uint256,uint256)",
+		"claim(uint256)",
+		"refund(uint256)",
+		"getPrice()",
+		"buy()",
+		"start()",
+		"bid()",
+		"withdraw()",
+		"end()",
+		"mint(uint256)",
+		"deploy(address,uint256)",
+	}
+)
+
+// 4-byte function selectors and signatures saved in database
+type SelectorDB struct {
+	db *database.Database
+}
+
+// create selector database with opened database, the built-in signatures are added
+func NewSelectorDB(db *database.Database) (*SelectorDB, error) {
+	_, err := db.ExecSql("create table if not exists selectors(selector text, signature text, primary key(selector, signature));")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SelectorDB{db: db}
+	signatures := append([]string{}, builtinSignatures...)
+	for _, data := range builtinABIs {
+		parsed, err := abi.JSON(strings.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		signatures = append(signatures, methodSignatures(&parsed)...)
+	}
+
+	_, err = s.AddSignatures(signatures)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// add function signatures in one transaction, return the number of new signatures
+func (s *SelectorDB) AddSignatures(signatures []string) (int, error) {
+	_, err := s.db.ExecSql("begin;")
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, signature := range signatures {
+		selector, canonical, err := Selector(signature)
+		if err != nil {
+			s.db.ExecSql("rollback;")
+			return 0, err
+		}
+
+		changes, err := s.db.ExecSql("insert or ignore into selectors(selector, signature) values(?, ?);", hexutil.Encode(selector), canonical)
+		if err != nil {
+			s.db.ExecSql("rollback;")
+			return 0, err
+		}
+
+		count += changes
+	}
+
+	_, err = s.db.ExecSql("commit;")
+	return count, err
+}
+
+// add all methods of abi
+func (s *SelectorDB) AddABI(parsed *abi.ABI) (int, error) {
+	return s.AddSignatures(methodSignatures(parsed))
+}
+
+// import signature list file, one signature each line and the selector before it is checked, like "0xa9059cbb transfer(address,uint256)"
+func (s *SelectorDB) ImportFile(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	signatures := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.Trim(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		signature := strings.Join(fields, "")
+		if len(fields) > 1 && strings.HasPrefix(fields[0], "0x") {
+			signature = strings.Join(fields[1:], "")
+			selector, _, err := Selector(signature)
+			if err != nil {
+				return 0, err
+			}
+
+			if hexutil.Encode(selector) != strings.ToLower(fields[0]) {
+				return 0, fmt.Errorf("Not match selector %s of %s", fields[0], signature)
+			}
+		}
+
+		signatures = append(signatures, signature)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	return s.AddSignatures(signatures)
+}
+
+// signatures of selector, multiple signatures if collided
+func (s *SelectorDB) Lookup(selector []byte) ([]string, error) {
+	rows, err := s.db.Query("select signature from selectors where selector = ? order by signature;", hexutil.Encode(selector))
+	if err != nil {
+		return nil, err
+	}
+
+	signatures := make([]string, 0, len(rows))
+	for _, row := range rows {
+		signatures = append(signatures, row[0].(string))
+	}
+
+	return signatures, nil
+}
+
+// identify function of call data and decode arguments, the signature must decode and re-encode the same data
+func (s *SelectorDB) Decode(data []byte) (string, error) {
+	if len(data) < 4 {
+		return "", errors.New("Invalid call data")
+	}
+
+	signatures, err := s.Lookup(data[:4])
+	if err != nil {
+		return "", err
+	}
+
+	if len(signatures) == 0 {
+		return "", errors.New("Unknown function selector " + hexutil.Encode(data[:4]))
+	}
+
+	for _, signature := range signatures {
+		funcname, argtypes, err := helper.ParseParams(signature)
+		if err != nil {
+			continue
+		}
+
+		arguments, err := parseTypes(argtypes)
+		if err != nil {
+			continue
+		}
+
+		values, err := arguments.Unpack(data[4:])
+		if err != nil {
+			continue
+		}
+
+		packed, err := arguments.Pack(values...)
+		if err != nil || !bytes.Equal(packed, data[4:]) {
+			continue
+		}
+
+		return formatCall(funcname, arguments, values)
+	}
+
+	return "", errors.New("Can not decode call data with signatures " + strings.Join(signatures, ","))
+}
+
+// selector and canonical signature of function, like transfer(address,uint)
+func Selector(signature string) ([]byte, string, error) {
+	funcname, argtypes, err := helper.ParseParams(signature)
+	if err != nil {
+		return nil, "", err
+	}
+
+	arguments, err := parseTypes(argtypes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	canonical := funcSignature(funcname, arguments)
+	return crypto.Keccak256([]byte(canonical))[:4], canonical, nil
+}
+
+func methodSignatures(parsed *abi.ABI) []string {
+	signatures := make([]string, 0, len(parsed.Methods))
+	for _, method := range parsed.Methods {
+		signatures = append(signatures, method.Sig)
+	}
+
+	return signatures
+}
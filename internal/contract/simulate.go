@@ -0,0 +1,77 @@
+package contract
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"utopia/internal/chain"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// simulate transaction of contract on evm chain
+func simulate(ctx context.Context, c chain.Chain, from string, to *common.Address, value *big.Int, data []byte) (*chain.Simulation, error) {
+	ethchain, ok := c.(*chain.EthChain)
+	if !ok {
+		return nil, errors.New("Dry run only support evm chain")
+	}
+
+	return ethchain.Simulate(ctx, ethereum.CallMsg{
+		From:  common.HexToAddress(from),
+		To:    to,
+		Value: value,
+		Data:  data,
+	})
+}
+
+// add token balance changes of sender and receiver to simulation, decimals is 0 for nft
+func addTokenChanges(ctx context.Context, c chain.Chain, sim *chain.Simulation, parsed *abi.ABI, token common.Address, decimals int, from string, to string, amount *big.Int) error {
+	if !sim.Success {
+		return nil
+	}
+
+	accounts := []string{from, to}
+	deltas := []*big.Int{new(big.Int).Neg(amount), amount}
+	for i, account := range accounts {
+		balance, err := tokenCall(ctx, c, parsed, token, "balanceOf", common.HexToAddress(account))
+		if err != nil {
+			return err
+		}
+
+		sim.AddChange(common.HexToAddress(account).Hex(), token.Hex(), decimals, balance.(*big.Int), deltas[i])
+	}
+
+	sim.Check()
+	return nil
+}
+
+// call read-only method of token and return the first output
+func tokenCall(ctx context.Context, c chain.Chain, parsed *abi.ABI, token common.Address, method string, args ...interface{}) (interface{}, error) {
+	ethchain, ok := c.(*chain.EthChain)
+	if !ok {
+		return nil, errors.New("Token call only support evm chain")
+	}
+
+	input, err := parsed.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := ethchain.Backend().CallContract(ctx, ethereum.CallMsg{To: &token, Data: input}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := parsed.Unpack(method, output)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		return nil, errors.New("Empty output of " + method)
+	}
+
+	return values[0], nil
+}
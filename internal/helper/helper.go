@@ -1,19 +1,23 @@
 package helper
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
+	"syscall"
 	"utopia/internal/cmc"
 	"utopia/internal/excel"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -21,6 +25,9 @@ var (
 	TRANSFER_SHEET_NAME  = "transfer"
 	TRANSFER_LIST_HEADER = []string{"index", "from", "to", "value", "notes"}
 
+	TOKEN_TRANSFER_SHEET_NAME  = "tokentransfer"
+	TOKEN_TRANSFER_LIST_HEADER = []string{"index", "from", "to", "id", "value", "notes"}
+
 	CURRENCY_SHEET_NAME  = "currency"
 	CURRENCY_LIST_HEADER = []string{"id", "symbol", "rank", "current", "total", "pairs", "platform", "address", "price", "marketcap", "lastupdated"}
 )
@@ -33,6 +40,15 @@ type TransferInfo struct {
 	Notes string
 }
 
+// transfer information of multi-token with id
+type TokenTransferInfo struct {
+	From  string
+	To    string
+	Id    string
+	Value string
+	Notes string
+}
+
 // create new app and init properties
 func NewApp(version string, usage string) *cli.App {
 	app := cli.NewApp()
@@ -46,6 +62,11 @@ func NewApp(version string, usage string) *cli.App {
 	return app
 }
 
+// create context which is canceled on interrupt signal
+func SignalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
 // change unit form wei to ether
 func WeiToEth(wei *big.Int) float32 {
 	eth, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), new(big.Float).SetInt(big.NewInt(1e+18))).Float32()
@@ -59,6 +80,62 @@ func EthToWei(eth float32) *big.Int {
 	return wei
 }
 
+// format integer amount in minimal unit to decimal string, like wei to eth
+func FormatAmount(value *big.Int, decimals int) string {
+	if value == nil {
+		return "0"
+	}
+
+	digits := new(big.Int).Abs(value).String()
+	if decimals > 0 {
+		if len(digits) <= decimals {
+			digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+		}
+
+		point := len(digits) - decimals
+		digits = strings.TrimRight(digits[:point]+"."+digits[point:], "0")
+		digits = strings.TrimSuffix(digits, ".")
+	}
+
+	if value.Sign() < 0 {
+		return "-" + digits
+	}
+
+	return digits
+}
+
+// parse decimal string to integer amount in minimal unit, like eth to wei
+func ParseAmount(amount string, decimals int) (*big.Int, error) {
+	amount = strings.TrimSpace(amount)
+	negative := strings.HasPrefix(amount, "-")
+	amount = strings.TrimPrefix(amount, "-")
+
+	parts := strings.Split(amount, ".")
+	if len(parts) > 2 || amount == "" {
+		return nil, errors.New("Invalid amount")
+	}
+
+	fraction := ""
+	if len(parts) == 2 {
+		fraction = parts[1]
+	}
+
+	if len(fraction) > decimals {
+		return nil, errors.New("Too many decimal places of amount")
+	}
+
+	value, ok := new(big.Int).SetString(parts[0]+fraction+strings.Repeat("0", decimals-len(fraction)), 10)
+	if !ok {
+		return nil, errors.New("Invalid amount")
+	}
+
+	if negative {
+		value.Neg(value)
+	}
+
+	return value, nil
+}
+
 func DefaultVlue(value string, def string) string {
 	if value == "" {
 		return def
@@ -67,183 +144,321 @@ func DefaultVlue(value string, def string) string {
 	return value
 }
 
-// parse function sig and call data
+// parse function sig and call data, the arguments are split by top level comma, like transfer(0x01,[1,2],(3,"a,b"))
 func ParseParams(params string) (string, []string, error) {
 	params = strings.Trim(params, " ")
 
 	// parse method
 	index := strings.Index(params, "(")
-	if index == -1 {
+	if index == -1 || !strings.HasSuffix(params, ")") {
 		return "", []string{}, errors.New("Invalid parameters")
 	}
 
 	method := strings.Trim(params[:index], " ")
-	params = params[index+1:]
-
-	index = strings.Index(params, ")")
-	if index == -1 {
-		return "", []string{}, errors.New("Invalid parameters")
+	args, err := SplitArgs(params[index+1 : len(params)-1])
+	if err != nil {
+		return "", []string{}, err
 	}
 
-	// parse parameters
-	params = params[:index]
-	args := strings.Split(params, ",")
-
 	return method, args, nil
 }
 
-// change call string value to dst type
-func Str2Type(input string, totype reflect.Type) (interface{}, error) {
-	input = strings.Trim(input, "\"")
-
-	switch totype {
-	case reflect.TypeOf(uint8(0)):
-	case reflect.TypeOf(uint16(0)):
-	case reflect.TypeOf(uint32(0)):
-	case reflect.TypeOf(uint64(0)):
-		// string to decimal in base 10
-		return strconv.ParseUint(strings.Trim(input, " "), 10, 64)
-	case reflect.TypeOf(int8(0)):
-	case reflect.TypeOf(int16(0)):
-	case reflect.TypeOf(int32(0)):
-	case reflect.TypeOf(int64(0)):
-		// string to decimal in base 10
-		return strconv.ParseInt(strings.Trim(input, " "), 10, 64)
-	case reflect.TypeOf(&big.Int{}):
-		// string to big.int in base 10
-		result, ok := new(big.Int).SetString(strings.Trim(input, " "), 10)
-		if !ok {
-			return nil, errors.New("Convert big.int failed")
-		}
-		return result, nil
-	case reflect.TypeOf(false):
-		// string to bool in base 10
-		return strconv.ParseBool(strings.Trim(input, " "))
-	case reflect.TypeOf(""):
-		// string return and not trim space
-		return input, nil
-	case reflect.TypeOf(common.Address{}):
-		// hex string to address
-		return common.HexToAddress(strings.Trim(input, " ")), nil
-	case reflect.ArrayOf(32, reflect.TypeOf(byte(0))):
-		// hex string to byte32
-		return common.FromHex(strings.Trim(input, " ")), nil
-	case reflect.SliceOf(reflect.TypeOf(byte(0))):
-		// hex string to []byte
-		return common.FromHex(strings.Trim(input, " ")), nil
-	default:
-		return nil, errors.New("Not support type")
-	}
-
-	return nil, nil
-}
+// split arguments by comma out of brackets and quotes
+func SplitArgs(input string) ([]string, error) {
+	args := make([]string, 0)
+	if strings.Trim(input, " ") == "" {
+		return args, nil
+	}
 
-// change result data to string value
-func Type2Str(input interface{}, itype reflect.Type) (string, error) {
-	switch itype {
-	case reflect.TypeOf(uint8(0)):
-	case reflect.TypeOf(uint16(0)):
-	case reflect.TypeOf(uint32(0)):
-	case reflect.TypeOf(uint64(0)):
-	case reflect.TypeOf(int8(0)):
-	case reflect.TypeOf(int16(0)):
-	case reflect.TypeOf(int32(0)):
-	case reflect.TypeOf(int64(0)):
-		// decimal to string
-		return fmt.Sprintf("%d", input), nil
-	case reflect.TypeOf(&big.Int{}):
-		// big.int to string
-		return input.(*big.Int).String(), nil
-	case reflect.TypeOf(false):
-		// bool to string
-		return fmt.Sprintf("%b", input), nil
-	case reflect.TypeOf(""):
-		// return string
-		return input.(string), nil
-	case reflect.TypeOf(common.Address{}):
-		// address to string
-		return input.(common.Address).Hex(), nil
-	case reflect.ArrayOf(32, reflect.TypeOf(byte(0))):
-	case reflect.SliceOf(reflect.TypeOf(byte(0))):
-		// []byte to string
-		return fmt.Sprintf("0x%s", common.Bytes2Hex(input.([]byte))), nil
-	default:
-		return "", errors.New("Not support type")
+	depth := 0
+	quoted := false
+	start := 0
+	for i := 0; i < len(input); i++ {
+		switch input[i] {
+		case '"':
+			if i == 0 || input[i-1] != '\\' {
+				quoted = !quoted
+			}
+		case '(', '[':
+			if !quoted {
+				depth++
+			}
+		case ')', ']':
+			if !quoted {
+				depth--
+				if depth < 0 {
+					return nil, errors.New("Unbalanced brackets of arguments")
+				}
+			}
+		case ',':
+			if !quoted && depth == 0 {
+				args = append(args, strings.Trim(input[start:i], " "))
+				start = i + 1
+			}
+		}
+	}
+
+	if quoted || depth != 0 {
+		return nil, errors.New("Unbalanced brackets of arguments")
 	}
 
-	return "", nil
+	return append(args, strings.Trim(input[start:], " ")), nil
 }
 
-// change string to array for call function
-func Str2Array(args []string, index int, totype abi.Type) (interface{}, int, error) {
-	if !strings.HasPrefix(args[index], "[") {
-		return nil, index, errors.New("Need array paramter but not found")
+// parse solidity type, like uint, bytes32[2] and (address,uint256[])[], uint and int are 256 bits
+func ParseType(input string) (abi.Type, error) {
+	input = strings.Trim(input, " ")
+	if !strings.HasPrefix(input, "(") {
+		input = strings.ToLower(input)
+		base := input
+		if index := strings.Index(input, "["); index != -1 {
+			base = input[:index]
+		}
+
+		if base == "uint" || base == "int" {
+			input = base + "256" + input[len(base):]
+		}
+
+		return abi.NewType(input, "", nil)
 	}
 
-	// max slice size is 256
-	result := reflect.MakeSlice(totype.GetType(), 0, 256)
-	inarray := true
+	// tuple type with components and array suffix
+	tuple, err := typeMarshaling("", input)
+	if err != nil {
+		return abi.Type{}, err
+	}
 
-	// for empty slice
-	if args[index] == "[]" {
-		return result.Interface(), index + 1, nil
+	return abi.NewType(tuple.Type, "", tuple.Components)
+}
+
+// component of tuple type for abi.NewType
+func typeMarshaling(name string, input string) (abi.ArgumentMarshaling, error) {
+	input = strings.Trim(input, " ")
+	if !strings.HasPrefix(input, "(") {
+		t, err := ParseType(input)
+		if err != nil {
+			return abi.ArgumentMarshaling{}, err
+		}
+
+		return abi.ArgumentMarshaling{Name: name, Type: t.String()}, nil
 	}
 
-	// delete [] for one element
-	args[index] = args[index][1:]
-	if strings.HasSuffix(args[index], "]") {
-		args[index] = args[index][:len(args[index])-1]
-		inarray = false
+	index := strings.LastIndex(input, ")")
+	if index == -1 {
+		return abi.ArgumentMarshaling{}, errors.New("Invalid tuple type " + input)
 	}
 
-	// change to dst type
-	v, err := Str2Type(args[index], totype.Elem.GetType())
+	elems, err := SplitArgs(input[1:index])
 	if err != nil {
-		return nil, index, err
+		return abi.ArgumentMarshaling{}, err
 	}
 
-	// add first element and interate the left
-	result = reflect.Append(result, reflect.ValueOf(v))
-	for {
-		index++
-		if !inarray {
-			break
+	components := make([]abi.ArgumentMarshaling, 0, len(elems))
+	for i, elem := range elems {
+		component, err := typeMarshaling(fmt.Sprintf("field%d", i), elem)
+		if err != nil {
+			return abi.ArgumentMarshaling{}, err
 		}
 
-		if len(args) <= index {
-			break
+		components = append(components, component)
+	}
+
+	return abi.ArgumentMarshaling{Name: name, Type: "tuple" + input[index+1:], Components: components}, nil
+}
+
+// change call string value to abi type, array is [a,b], tuple is (a,b) or [a,b], integer can be negative or hex
+func Str2Value(input string, t abi.Type) (interface{}, error) {
+	input = strings.Trim(input, " ")
+
+	switch t.T {
+	case abi.SliceTy, abi.ArrayTy:
+		if !strings.HasPrefix(input, "[") || !strings.HasSuffix(input, "]") {
+			return nil, errors.New("Need array parameter but " + input)
+		}
+
+		items, err := SplitArgs(input[1 : len(input)-1])
+		if err != nil {
+			return nil, err
+		}
+
+		var result reflect.Value
+		if t.T == abi.SliceTy {
+			result = reflect.MakeSlice(t.GetType(), len(items), len(items))
+		} else {
+			if len(items) != t.Size {
+				return nil, fmt.Errorf("Need %d elements of array but %d", t.Size, len(items))
+			}
+
+			result = reflect.New(t.GetType()).Elem()
 		}
 
-		if strings.HasSuffix(args[index], "]") {
-			args[index] = args[index][:len(args[index])-1]
-			inarray = false
+		for i, item := range items {
+			v, err := Str2Value(item, *t.Elem)
+			if err != nil {
+				return nil, err
+			}
+
+			result.Index(i).Set(reflect.ValueOf(v))
+		}
+
+		return result.Interface(), nil
+	case abi.TupleTy:
+		if !(strings.HasPrefix(input, "(") && strings.HasSuffix(input, ")")) && !(strings.HasPrefix(input, "[") && strings.HasSuffix(input, "]")) {
+			return nil, errors.New("Need tuple parameter but " + input)
+		}
+
+		items, err := SplitArgs(input[1 : len(input)-1])
+		if err != nil {
+			return nil, err
+		}
+
+		if len(items) != len(t.TupleElems) {
+			return nil, fmt.Errorf("Need %d fields of tuple but %d", len(t.TupleElems), len(items))
+		}
+
+		result := reflect.New(t.GetType()).Elem()
+		for i, item := range items {
+			v, err := Str2Value(item, *t.TupleElems[i])
+			if err != nil {
+				return nil, err
+			}
+
+			result.Field(i).Set(reflect.ValueOf(v))
+		}
+
+		return result.Interface(), nil
+	case abi.IntTy, abi.UintTy:
+		// decimal or hex with 0x prefix
+		value, ok := new(big.Int).SetString(input, 0)
+		if !ok {
+			return nil, errors.New("Invalid integer " + input)
+		}
+
+		min, max := big.NewInt(0), new(big.Int).Lsh(big.NewInt(1), uint(t.Size))
+		if t.T == abi.IntTy {
+			max.Rsh(max, 1)
+			min.Neg(max)
+		}
+
+		if value.Cmp(min) < 0 || value.Cmp(max) >= 0 {
+			return nil, fmt.Errorf("Integer %s out of range of %s", input, t.String())
+		}
+
+		// uint8 to uint64 and int8 to int64 are native types, others are *big.Int
+		if t.GetType().Kind() == reflect.Ptr {
+			return value, nil
+		} else if t.T == abi.IntTy {
+			return reflect.ValueOf(value.Int64()).Convert(t.GetType()).Interface(), nil
+		}
+
+		return reflect.ValueOf(value.Uint64()).Convert(t.GetType()).Interface(), nil
+	case abi.BoolTy:
+		return strconv.ParseBool(input)
+	case abi.StringTy:
+		// string in quotes can contain comma and brackets
+		if len(input) >= 2 && strings.HasPrefix(input, "\"") && strings.HasSuffix(input, "\"") {
+			return strings.ReplaceAll(input[1:len(input)-1], "\\\"", "\""), nil
+		}
+
+		return input, nil
+	case abi.AddressTy:
+		input = strings.Trim(input, "\"")
+		if !common.IsHexAddress(input) {
+			return nil, errors.New("Invalid address " + input)
 		}
 
-		v, err = Str2Type(args[index], totype.Elem.GetType())
+		return common.HexToAddress(input), nil
+	case abi.FixedBytesTy:
+		// hex is padded right like bytes32 in solidity
+		data, err := hexutil.Decode(strings.Trim(input, "\""))
 		if err != nil {
-			return nil, index, err
+			return nil, err
 		}
 
-		result = reflect.Append(result, reflect.ValueOf(v))
+		if len(data) > t.Size {
+			return nil, fmt.Errorf("Too long data for %s", t.String())
+		}
+
+		result := reflect.New(t.GetType()).Elem()
+		reflect.Copy(result, reflect.ValueOf(data))
+		return result.Interface(), nil
+	case abi.BytesTy:
+		return hexutil.Decode(strings.Trim(input, "\""))
+	}
+
+	return nil, errors.New("Not support type " + t.String())
+}
+
+// change result data to string value
+func Type2Str(input interface{}) (string, error) {
+	switch v := input.(type) {
+	case uint8, uint16, uint32, uint64, int8, int16, int32, int64:
+		// decimal to string
+		return fmt.Sprintf("%d", v), nil
+	case *big.Int:
+		// big.int to string
+		return v.String(), nil
+	case bool:
+		// bool to string
+		return strconv.FormatBool(v), nil
+	case string:
+		// return string
+		return v, nil
+	case common.Address:
+		// address to string
+		return v.Hex(), nil
+	case common.Hash:
+		// indexed dynamic value in event topic
+		return v.Hex(), nil
+	case []byte:
+		// []byte to string
+		return fmt.Sprintf("0x%s", common.Bytes2Hex(v)), nil
 	}
 
-	// check array is completed
-	if inarray {
-		return nil, index, errors.New("Invalid array values")
+	value := reflect.ValueOf(input)
+	switch value.Kind() {
+	case reflect.Array, reflect.Slice:
+		// fixed bytes to hex, other array to list
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, value.Len())
+			reflect.Copy(reflect.ValueOf(data), value)
+			return fmt.Sprintf("0x%s", common.Bytes2Hex(data)), nil
+		}
+
+		return Array2Str(input)
+	case reflect.Struct:
+		// tuple to (field1,field2)
+		items := make([]string, 0, value.NumField())
+		for i := 0; i < value.NumField(); i++ {
+			item, err := Type2Str(value.Field(i).Interface())
+			if err != nil {
+				return "", err
+			}
+
+			items = append(items, item)
+		}
+
+		return "(" + strings.Join(items, ",") + ")", nil
+	case reflect.Ptr:
+		if value.IsNil() {
+			return "", nil
+		}
+
+		return Type2Str(value.Elem().Interface())
 	}
 
-	return result.Interface(), index, nil
+	return "", errors.New("Not support type")
 }
 
 // change array to string for call result
-func Array2Str(input interface{}, totype reflect.Type) (string, error) {
+func Array2Str(input interface{}) (string, error) {
 	var builder strings.Builder
 	builder.WriteString("[")
 
 	// iterate times to array size
 	size := reflect.ValueOf(input).Len()
 	for i := 0; i < size; i++ {
-		output, err := Type2Str(reflect.ValueOf(input).Index(i).Interface(), totype)
+		output, err := Type2Str(reflect.ValueOf(input).Index(i).Interface())
 		if err != nil {
 			return "", err
 		}
@@ -258,6 +473,67 @@ func Array2Str(input interface{}, totype reflect.Type) (string, error) {
 	return builder.String(), nil
 }
 
+// format unpacked values by abi arguments
+func Outputs2Str(arguments abi.Arguments, values []interface{}) ([]string, error) {
+	if len(values) != len(arguments) {
+		return nil, errors.New("Not match output number")
+	}
+
+	result := make([]string, 0, len(values))
+	for i, argument := range arguments {
+		output, err := Abi2Str(values[i], argument.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, output)
+	}
+
+	return result, nil
+}
+
+// change decoded value to string by abi type, uint8[] is list but not bytes
+func Abi2Str(input interface{}, t abi.Type) (string, error) {
+	// indexed dynamic value in event topic is hash
+	if hash, ok := input.(common.Hash); ok {
+		return hash.Hex(), nil
+	}
+
+	value := reflect.ValueOf(input)
+	switch t.T {
+	case abi.SliceTy, abi.ArrayTy:
+		items := make([]string, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			item, err := Abi2Str(value.Index(i).Interface(), *t.Elem)
+			if err != nil {
+				return "", err
+			}
+
+			items = append(items, item)
+		}
+
+		return "[" + strings.Join(items, ",") + "]", nil
+	case abi.TupleTy:
+		if value.Kind() == reflect.Ptr {
+			value = value.Elem()
+		}
+
+		items := make([]string, 0, len(t.TupleElems))
+		for i, elem := range t.TupleElems {
+			item, err := Abi2Str(value.Field(i).Interface(), *elem)
+			if err != nil {
+				return "", err
+			}
+
+			items = append(items, item)
+		}
+
+		return "(" + strings.Join(items, ",") + ")", nil
+	}
+
+	return Type2Str(input)
+}
+
 func ReadTransferFile(path string) ([]TransferInfo, error) {
 	// open excel file to read list
 	file, err := excel.NewExcel(path)
@@ -330,6 +606,80 @@ func SaveTransferFile(info []TransferInfo, path string) error {
 	return file.WriteAll(TRANSFER_SHEET_NAME, data)
 }
 
+func ReadTokenTransferFile(path string) ([]TokenTransferInfo, error) {
+	// open excel file to read list
+	file, err := excel.NewExcel(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close(false)
+
+	data, err := file.ReadAll(TOKEN_TRANSFER_SHEET_NAME)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]TokenTransferInfo, 0)
+	for index, row := range data {
+		// skip the header
+		if index == 0 {
+			continue
+		}
+
+		if len(row) < len(TOKEN_TRANSFER_LIST_HEADER) {
+			return nil, errors.New("Invalid file format")
+		}
+
+		// {"index", "from", "to", "id", "value", "notes"}
+		result = append(result, TokenTransferInfo{
+			From:  row[1],
+			To:    row[2],
+			Id:    row[3],
+			Value: row[4],
+			Notes: row[5],
+		})
+	}
+
+	return result, nil
+}
+
+func SaveTokenTransferFile(info []TokenTransferInfo, path string) error {
+	// open excel file to write list
+	file, err := excel.NewExcel(path)
+	if err != nil {
+		return err
+	}
+
+	err = file.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close(true)
+
+	data := make([][]string, 0)
+	data = append(data, TOKEN_TRANSFER_LIST_HEADER)
+
+	// {"index", "from", "to", "id", "value", "notes"}
+	for i, key := range info {
+		row := make([]string, 0, len(TOKEN_TRANSFER_LIST_HEADER))
+		row = append(row, strconv.Itoa(i+1))
+		row = append(row, DefaultVlue(key.From, "0x"))
+		row = append(row, DefaultVlue(key.To, "0x"))
+		row = append(row, DefaultVlue(key.Id, "0"))
+		row = append(row, DefaultVlue(key.Value, "0"))
+		row = append(row, DefaultVlue(key.Notes, "x"))
+
+		data = append(data, row)
+	}
+
+	return file.WriteAll(TOKEN_TRANSFER_SHEET_NAME, data)
+}
+
 func WriteCurrencyFile(path string, money string, list []*cmc.Listing) error {
 	// open excel file to write list
 	file, err := excel.NewExcel(path)
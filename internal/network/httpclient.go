@@ -2,6 +2,7 @@ package network
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -52,6 +53,35 @@ func HttpGet(url string, params []string, header map[string]string) ([]byte, err
 	return body, nil
 }
 
+// get url until ctx is done, the timeout is set by ctx
+func HttpGetContext(ctx context.Context, url string, header map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range header {
+		req.Header.Add(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if 200 != resp.StatusCode {
+		return nil, fmt.Errorf("%s", body)
+	}
+
+	return body, nil
+}
+
 func HttpPost(url string, data []byte, header map[string]string) ([]byte, error) {
 	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"utopia/internal/excel"
 	"utopia/internal/helper"
 )
@@ -17,11 +18,18 @@ var (
 
 func GetWallet(address string) (Wallet, error) {
 	w, ok := AccountList[address]
-	if !ok {
-		return nil, errors.New("Address is not exist")
+	if ok {
+		return w, nil
+	}
+
+	// address maybe in other case of checksum
+	for k, w := range AccountList {
+		if strings.EqualFold(k, address) {
+			return w, nil
+		}
 	}
 
-	return w, nil
+	return nil, errors.New("Address is not exist")
 }
 
 func AddWallet(address string, wallet Wallet) error {
@@ -64,9 +72,14 @@ func LoadAccountList(path string) error {
 			return errors.New("Invalid file format")
 		}
 
-		// create wallet and load private key
+		// create wallet and load private key, the WIF key is bitcoin wallet
 		w := NewWallet(WALLET_ETH, row[3], row[4])
-		if row[2] != "" {
+		if IsWIF(row[2]) {
+			btc := NewWallet(WALLET_BTC, row[3], row[4]).(*BtcWallet)
+			btc.SetPrivateKey(row[2])
+			btc.SetAddress(row[1])
+			w = btc
+		} else if row[2] != "" {
 			w.SetPrivateKey(row[2])
 		} else {
 			err = w.LoadKey()
@@ -84,6 +97,37 @@ func LoadAccountList(path string) error {
 	return nil
 }
 
+// read address column of accounts sheet without loading keys
+func ReadAddressList(path string) ([]string, error) {
+	file, err := excel.NewExcel(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close(false)
+
+	data, err := file.ReadAll(ACCOUNTS_SHEET_NAME)
+	if err != nil {
+		return nil, err
+	}
+
+	// [index, address, ...]
+	addresses := make([]string, 0, len(data))
+	for index, row := range data {
+		if index == 0 || len(row) < 2 || row[1] == "" {
+			continue
+		}
+
+		addresses = append(addresses, row[1])
+	}
+
+	return addresses, nil
+}
+
 func SaveAccountList(path string) error {
 	file, err := excel.NewExcel(path)
 	if err != nil {
@@ -1,10 +1,18 @@
 package wallet
 
-import "os"
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+const BASE58_ALPHABET = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
 
 type BtcWallet struct {
 	path     string
 	password string
+	key      string // Private key in WIF format
+	address  string // Address of key, empty to derive by node
 }
 
 func NewBtcWallet(path string, password string) Wallet {
@@ -12,11 +20,11 @@ func NewBtcWallet(path string, password string) Wallet {
 }
 
 func (w *BtcWallet) Address() string {
-	return ""
+	return w.address
 }
 
 func (w *BtcWallet) PrivateKey() string {
-	return ""
+	return w.key
 }
 
 func (w *BtcWallet) PublicKey() string {
@@ -36,9 +44,34 @@ func (w *BtcWallet) GenerateKey() error {
 }
 
 func (w *BtcWallet) SetPrivateKey(key string) error {
+	if !IsWIF(key) {
+		return errors.New("Invalid WIF private key")
+	}
+
+	w.key = key
 	return nil
 }
 
+// set address of the private key
+func (w *BtcWallet) SetAddress(address string) {
+	w.address = address
+}
+
+// private key in wallet import format, base58 string of 51 or 52 chars
+func IsWIF(key string) bool {
+	if len(key) != 51 && len(key) != 52 {
+		return false
+	}
+
+	for _, c := range key {
+		if !strings.ContainsRune(BASE58_ALPHABET, c) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (w *BtcWallet) SaveKey() error {
 	return nil
 }
@@ -1,15 +1,31 @@
 package tests
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 	"utopia/internal/chain"
+	"utopia/internal/database"
 	"utopia/internal/helper"
+	"utopia/internal/wallet"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 var (
@@ -21,12 +37,12 @@ var (
 )
 
 func connectChain() (chain.Chain, error) {
-	c := chain.NewChain(1, "ETH", "eth")
-	if c == nil {
-		return nil, errors.New("New chain failed")
+	c, err := chain.NewChain(context.Background(), 1, "ETH", "eth")
+	if err != nil {
+		return nil, err
 	}
 
-	return c, c.Connect([]string{"https://rpc.ankr.com/eth"}, true)
+	return c, c.Connect(context.Background(), []string{"https://rpc.ankr.com/eth"}, true)
 }
 
 func TestChainID(t *testing.T) {
@@ -37,7 +53,7 @@ func TestChainID(t *testing.T) {
 	}
 	defer c.DisConnect()
 
-	id, err := c.ChainId()
+	id, err := c.ChainId(context.Background())
 	if id.Cmp(big.NewInt(1)) != 0 {
 		t.Errorf("Chain id expect %d but %d", 1, id.Int64())
 	}
@@ -51,7 +67,7 @@ func TestGasPrice(t *testing.T) {
 	}
 	defer c.DisConnect()
 
-	gas, err := c.GasPrice()
+	gas, err := c.GasPrice(context.Background())
 	if gas.Cmp(big.NewInt(0)) <= 0 {
 		t.Errorf("Gas price expect >0 but %d", gas.Int64())
 	}
@@ -65,7 +81,7 @@ func TestBlockNumber(t *testing.T) {
 	}
 	defer c.DisConnect()
 
-	num, err := c.BlockNumber()
+	num, err := c.BlockNumber(context.Background())
 	if num <= 0 {
 		t.Errorf("Block number expect >0 but %d", num)
 	}
@@ -79,9 +95,9 @@ func TestBlockByNumber(t *testing.T) {
 	}
 	defer c.DisConnect()
 
-	b, err := c.BlockByNumber(blockNumber)
-	if b.Number().Uint64() != blockNumber {
-		t.Errorf("Block by number expect %d but %d", blockNumber, b.Number().Uint64())
+	b, err := c.BlockByNumber(context.Background(), blockNumber)
+	if b.Number != blockNumber {
+		t.Errorf("Block by number expect %d but %d", blockNumber, b.Number)
 	}
 }
 
@@ -93,9 +109,9 @@ func TestBlockByHash(t *testing.T) {
 	}
 	defer c.DisConnect()
 
-	b, err := c.BlockByHash(common.FromHex(blockHash))
-	if b.Hash().Hex() != blockHash {
-		t.Errorf("Block by hash expect %s but %s", blockHash, b.Hash().Hex())
+	b, err := c.BlockByHash(context.Background(), blockHash)
+	if b.Hash != blockHash {
+		t.Errorf("Block by hash expect %s but %s", blockHash, b.Hash)
 	}
 }
 
@@ -107,14 +123,14 @@ func TestTransaction(t *testing.T) {
 	}
 	defer c.DisConnect()
 
-	tx, _, err := c.Transaction(common.FromHex(txHash))
+	tx, err := c.Transaction(context.Background(), txHash)
 	if err != nil {
 		t.Errorf("Find transaction failed with error: %v", err)
 		return
 	}
 
-	if tx.Hash().Hex() != txHash {
-		t.Errorf("Transaction expect %s but %s", txHash, tx.Hash().Hex())
+	if tx.Hash != txHash {
+		t.Errorf("Transaction expect %s but %s", txHash, tx.Hash)
 	}
 }
 
@@ -126,9 +142,9 @@ func TestReceipt(t *testing.T) {
 	}
 	defer c.DisConnect()
 
-	r, err := c.Receipt(common.FromHex(txHash))
-	if r.TxHash.Hex() != txHash {
-		t.Errorf("Transaction expect %s but %s", txHash, r.TxHash.Hex())
+	r, err := c.Receipt(context.Background(), txHash)
+	if r.TxHash != txHash {
+		t.Errorf("Transaction expect %s but %s", txHash, r.TxHash)
 	}
 }
 
@@ -151,7 +167,8 @@ func TestEstimateGas(t *testing.T) {
 	}
 	defer c.DisConnect()
 
-	tx, _, err := c.Transaction(common.FromHex(txHash))
+	evm := c.(chain.EvmChain)
+	tx, _, err := evm.EthTransaction(context.Background(), common.FromHex(txHash))
 	if err != nil {
 		t.Errorf("Find transaction failed with error: %v", err)
 		return
@@ -161,12 +178,12 @@ func TestEstimateGas(t *testing.T) {
 		t.Errorf("Transaction expect %s but %s", txHash, tx.Hash().Hex())
 	}
 
-	r, err := c.Receipt(common.FromHex(txHash))
+	r, err := evm.EthReceipt(context.Background(), common.FromHex(txHash))
 	if r.TxHash.Hex() != txHash {
 		t.Errorf("Transaction expect %s but %s", txHash, r.TxHash.Hex())
 	}
 
-	gas, err := c.EstimateGas(tx)
+	gas, err := evm.EstimateGas(context.Background(), tx)
 	if gas <= 0 && !strings.HasPrefix(err.Error(), "execution reverted") {
 		t.Errorf("Estimate gas expect >0 but %d %v", gas, err)
 	}
@@ -180,7 +197,7 @@ func TestBalance(t *testing.T) {
 	}
 	defer c.DisConnect()
 
-	balance, err := c.Balance(account)
+	balance, err := c.Balance(context.Background(), account)
 	if helper.WeiToEth(balance) <= 0 {
 		t.Errorf("Balance expect >0 but %f", helper.WeiToEth(balance))
 	}
@@ -194,7 +211,7 @@ func TestNonce(t *testing.T) {
 	}
 	defer c.DisConnect()
 
-	nonce, err := c.Nonce(account)
+	nonce, err := c.Nonce(context.Background(), account)
 	if nonce <= 0 {
 		t.Errorf("Nonce expect >0 but %d", nonce)
 	}
@@ -208,7 +225,7 @@ func TestCode(t *testing.T) {
 	}
 	defer c.DisConnect()
 
-	code, err := c.Code(contract)
+	code, err := c.Code(context.Background(), contract)
 	if len(code) <= 0 {
 		t.Errorf("Expect code size >0 but 0")
 	}
@@ -253,3 +270,1196 @@ func TestMeta(t *testing.T) {
 		return
 	}
 }
+
+// json-rpc stub server, handler returns result of method
+func newRpcServer(handler func(method string, params []json.RawMessage) (interface{}, error)) *httptest.Server {
+	type request struct {
+		Id     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+
+	serve := func(req request) map[string]interface{} {
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.Id}
+		result, err := handler(req.Method, req.Params)
+		if err != nil {
+			resp["error"] = map[string]interface{}{"code": -32000, "message": err.Error()}
+		} else {
+			resp["result"] = result
+		}
+
+		return resp
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+
+		// support batch request
+		if strings.HasPrefix(strings.TrimSpace(string(body)), "[") {
+			reqs := make([]request, 0)
+			json.Unmarshal(body, &reqs)
+
+			resps := make([]interface{}, 0, len(reqs))
+			for _, req := range reqs {
+				resps = append(resps, serve(req))
+			}
+
+			json.NewEncoder(w).Encode(resps)
+			return
+		}
+
+		var req request
+		json.Unmarshal(body, &req)
+		json.NewEncoder(w).Encode(serve(req))
+	}))
+}
+
+func TestFailover(t *testing.T) {
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		switch method {
+		case "eth_chainId":
+			return "0x1", nil
+		case "eth_blockNumber":
+			return "0x64", nil
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	// the first server is not reachable
+	c := &chain.EthChain{Id: 1, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{"http://127.0.0.1:1", server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	num, err := c.BlockNumber(context.Background())
+	if err != nil || num != 100 {
+		t.Errorf("Block number expect 100 but %d %v", num, err)
+		return
+	}
+
+	health := c.Health()
+	if len(health) != 2 || health[0].Healthy || !health[1].Healthy {
+		t.Errorf("Health status not expected %v", health)
+		return
+	}
+
+	// rpc error is not a transport failure
+	_, err = c.Code(context.Background(), account)
+	if err == nil {
+		t.Errorf("Expect method error but nil")
+		return
+	}
+
+	health = c.Health()
+	if !health[1].Healthy || health[1].Failure != 0 {
+		t.Errorf("Health status not expected %v", health[1])
+		return
+	}
+
+	// probe reports every server without connect even if all are down
+	down := &chain.EthChain{Id: 1, Timeout: time.Second, Rpc: []string{"http://127.0.0.1:1", "http://127.0.0.1:2"}}
+	health = down.Probe(context.Background())
+	if len(health) != 2 || health[0].Healthy || health[1].Healthy || health[0].LastError == "" || health[1].Failure != 1 {
+		t.Errorf("Probe status not expected %v", health)
+	}
+}
+
+func TestSuggestFee(t *testing.T) {
+	london := true
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		switch method {
+		case "eth_feeHistory":
+			if !london {
+				return nil, errors.New("method not found")
+			}
+
+			return map[string]interface{}{
+				"oldestBlock":   "0x1",
+				"baseFeePerGas": []string{"0x64", "0x64", "0xc8"},
+				"reward":        [][]string{{"0xa"}, {"0x14"}},
+				"gasUsedRatio":  []float64{0.5, 0.5},
+			}, nil
+		case "eth_gasPrice":
+			return "0x3e8", nil
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second, Fee: chain.NewFeeStrategy(chain.FEE_MODE_AUTO)}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	// base fee 200 and average tip 15
+	fee, err := c.SuggestFee(context.Background())
+	if err != nil || !fee.IsDynamic() || fee.GasTipCap.Int64() != 15 || fee.GasFeeCap.Int64() != 415 {
+		t.Errorf("Dynamic fee not expected %v %v", fee, err)
+		return
+	}
+
+	// fall back to legacy gas price
+	london = false
+	fee, err = c.SuggestFee(context.Background())
+	if err != nil || fee.IsDynamic() || fee.GasPrice.Int64() != 1000 {
+		t.Errorf("Legacy fee not expected %v %v", fee, err)
+		return
+	}
+
+	c.Fee = chain.NewFeeStrategy(chain.FEE_MODE_DYNAMIC)
+	_, err = c.SuggestFee(context.Background())
+	if err == nil {
+		t.Errorf("Expect dynamic fee error but nil")
+	}
+}
+
+func TestTracker(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	to := common.HexToAddress(account)
+	tx, _ := types.SignTx(types.NewTransaction(1, to, big.NewInt(1), 21000, big.NewInt(10), nil), types.NewLondonSigner(big.NewInt(1)), key)
+	txdata, _ := tx.MarshalJSON()
+
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		switch method {
+		case "eth_blockNumber":
+			return "0xb", nil
+		case "eth_getTransactionByHash":
+			return json.RawMessage(txdata), nil
+		case "eth_getTransactionReceipt":
+			return map[string]interface{}{
+				"status":            "0x1",
+				"cumulativeGasUsed": "0x5208",
+				"logsBloom":         "0x" + strings.Repeat("00", 256),
+				"logs":              []interface{}{},
+				"transactionHash":   tx.Hash().Hex(),
+				"contractAddress":   "0x0000000000000000000000000000000000000000",
+				"gasUsed":           "0x5208",
+				"blockHash":         blockHash,
+				"blockNumber":       "0xa",
+				"transactionIndex":  "0x0",
+			}, nil
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	tracker := chain.NewTracker(c, 2)
+	tracker.Interval = 10 * time.Millisecond
+
+	result, err := tracker.Wait(context.Background(), tx.Hash().Hex())
+	if err != nil {
+		t.Errorf("Wait transaction failed with error: %v", err)
+		return
+	}
+
+	if result.Status != chain.TX_STATUS_SUCCESS || result.Confirmations != 2 || result.GasUsed != 21000 || result.Fee.Int64() != 210000 {
+		t.Errorf("Transaction result not expected %s", result)
+	}
+
+	// receipt query fails twice with bad gateway and is retried
+	failures := 2
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if failures > 0 && strings.Contains(string(body), "eth_getTransactionReceipt") {
+			failures--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		server.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer flaky.Close()
+
+	err = c.Connect(context.Background(), []string{flaky.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+
+	result, err = tracker.Wait(context.Background(), tx.Hash().Hex())
+	if err != nil || result.Status != chain.TX_STATUS_SUCCESS || failures != 0 {
+		t.Errorf("Wait transaction with transport error failed with error: %v", err)
+	}
+}
+
+func TestNonceManager(t *testing.T) {
+	pending := uint64(5)
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		if method == "eth_getTransactionCount" {
+			return fmt.Sprintf("0x%x", pending), nil
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	m := chain.NewNonceManager()
+	n1, _ := m.Acquire(context.Background(), c, account)
+	n2, _ := m.Acquire(context.Background(), c, account)
+	if n1 != 5 || n2 != 6 {
+		t.Errorf("Expect nonce 5 and 6 but %d %d", n1, n2)
+		return
+	}
+
+	// released nonce fill the gap
+	m.Release(c, account, n1)
+	n3, _ := m.Acquire(context.Background(), c, account)
+	n4, _ := m.Acquire(context.Background(), c, account)
+	if n3 != 5 || n4 != 7 {
+		t.Errorf("Expect nonce 5 and 7 but %d %d", n3, n4)
+		return
+	}
+
+	// sync with chain which is ahead
+	pending = 10
+	m.Reset(c, account)
+	n5, _ := m.Acquire(context.Background(), c, account)
+	if n5 != 10 {
+		t.Errorf("Expect nonce 10 but %d", n5)
+	}
+}
+
+func TestReplacer(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	w := wallet.NewWallet(wallet.WALLET_ETH, "", "")
+	w.SetPrivateKey(hexutil.Encode(crypto.FromECDSA(key)))
+
+	access := types.AccessList{{Address: common.HexToAddress(contract), StorageKeys: []common.Hash{{1}}}}
+	tx, _ := types.SignTx(types.NewTx(&types.DynamicFeeTx{
+		ChainID:    big.NewInt(1),
+		Nonce:      3,
+		GasTipCap:  big.NewInt(100),
+		GasFeeCap:  big.NewInt(1000),
+		Gas:        50000,
+		Value:      big.NewInt(0),
+		Data:       []byte{1, 2, 3},
+		AccessList: access,
+	}), types.NewLondonSigner(big.NewInt(1)), key)
+
+	var sent *types.Transaction
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		if method == "eth_sendRawTransaction" {
+			var data hexutil.Bytes
+			json.Unmarshal(params[0], &data)
+			sent = new(types.Transaction)
+			return nil, sent.UnmarshalBinary(data)
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	// low suggested fee is bumped by minimum percent, contract creation and access list are kept
+	replacer := chain.NewReplacer(c)
+	_, err = replacer.SpeedUp(context.Background(), tx, &chain.Fee{GasFeeCap: big.NewInt(500), GasTipCap: big.NewInt(50)}, w)
+	if err != nil {
+		t.Errorf("Speed up transaction failed with error: %v", err)
+		return
+	}
+
+	if sent.Type() != types.DynamicFeeTxType || sent.Nonce() != 3 || sent.To() != nil || len(sent.AccessList()) != 1 {
+		t.Errorf("Replacement not keep the transaction content")
+	}
+
+	if sent.GasFeeCap().Int64() != 1100 || sent.GasTipCap().Int64() != 110 {
+		t.Errorf("Expect fee 1100 and tip 110 but %d %d", sent.GasFeeCap(), sent.GasTipCap())
+	}
+
+	// cancel is zero value self transfer
+	_, err = replacer.Cancel(context.Background(), tx, &chain.Fee{GasFeeCap: big.NewInt(2000), GasTipCap: big.NewInt(200)}, w)
+	if err != nil {
+		t.Errorf("Cancel transaction failed with error: %v", err)
+		return
+	}
+
+	if sent.To() == nil || !strings.EqualFold(sent.To().Hex(), w.Address()) || sent.Value().Sign() != 0 || sent.Gas() != 21000 || sent.GasFeeCap().Int64() != 2000 {
+		t.Errorf("Cancel transaction not expected")
+	}
+
+	// only sender can replace transaction
+	other := wallet.NewWallet(wallet.WALLET_ETH, "", "")
+	otherKey, _ := crypto.GenerateKey()
+	other.SetPrivateKey(hexutil.Encode(crypto.FromECDSA(otherKey)))
+	_, err = replacer.Cancel(context.Background(), tx, &chain.Fee{GasFeeCap: big.NewInt(2000), GasTipCap: big.NewInt(200)}, other)
+	if err == nil {
+		t.Errorf("Expect sender error but nil")
+	}
+}
+
+func TestBatch(t *testing.T) {
+	calls := 0
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		calls++
+		var address string
+		json.Unmarshal(params[0], &address)
+
+		switch method {
+		case "eth_getBalance":
+			if strings.HasSuffix(address, "3") {
+				return nil, errors.New("balance error")
+			}
+			return "0x" + address[len(address)-1:], nil
+		case "eth_getTransactionReceipt":
+			return nil, nil
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second, BatchSize: 2}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	// failed request not affect the others
+	addresses := []string{"0x01", "0x02", "0x03"}
+	balances, err := c.BatchBalance(context.Background(), addresses)
+	batchErr, ok := err.(*chain.BatchError)
+	if !ok || batchErr.Errors[2] == nil || batchErr.Errors[0] != nil {
+		t.Errorf("Expect batch error of last request but %v", err)
+		return
+	}
+
+	if balances[0].Int64() != 1 || balances[1].Int64() != 2 || balances[2] != nil {
+		t.Errorf("Balances not expected %v", balances)
+	}
+
+	if calls != 3 {
+		t.Errorf("Expect 3 requests but %d", calls)
+	}
+
+	// null receipt is not found
+	_, err = c.BatchReceipt(context.Background(), [][]byte{common.FromHex(txHash)})
+	batchErr, ok = err.(*chain.BatchError)
+	if !ok || batchErr.Errors[0] != ethereum.NotFound {
+		t.Errorf("Expect not found error but %v", err)
+	}
+}
+
+func TestFilterLogs(t *testing.T) {
+	// node limits range to 10 blocks, return one log each block
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		if method != "eth_getLogs" {
+			return nil, errors.New("method not found")
+		}
+
+		var query struct {
+			FromBlock hexutil.Uint64 `json:"fromBlock"`
+			ToBlock   hexutil.Uint64 `json:"toBlock"`
+		}
+		json.Unmarshal(params[0], &query)
+
+		if query.ToBlock-query.FromBlock >= 10 {
+			return nil, errors.New("query returned more than 10000 results")
+		}
+
+		logs := make([]interface{}, 0)
+		for n := query.FromBlock; n <= query.ToBlock; n++ {
+			logs = append(logs, map[string]interface{}{
+				"address":          contract,
+				"topics":           []string{},
+				"data":             "0x",
+				"blockNumber":      n.String(),
+				"transactionHash":  txHash,
+				"transactionIndex": "0x0",
+				"blockHash":        blockHash,
+				"logIndex":         "0x0",
+				"removed":          false,
+			})
+		}
+
+		return logs, nil
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	logs, err := c.FilterLogs(context.Background(), ethereum.FilterQuery{FromBlock: big.NewInt(0), ToBlock: big.NewInt(100)})
+	if err != nil {
+		t.Errorf("Filter logs failed with error: %v", err)
+		return
+	}
+
+	if len(logs) != 101 || logs[100].BlockNumber != 100 {
+		t.Errorf("Expect 101 logs but %d", len(logs))
+	}
+}
+
+func TestSubscribeHeads(t *testing.T) {
+	number := uint64(10)
+	lock := sync.Mutex{}
+
+	// block number increases on each query
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch method {
+		case "eth_blockNumber":
+			number++
+			return hexutil.EncodeUint64(number), nil
+		case "eth_getBlockByNumber":
+			var n hexutil.Uint64
+			json.Unmarshal(params[0], &n)
+			return map[string]interface{}{
+				"parentHash":       blockHash,
+				"sha3Uncles":       blockHash,
+				"miner":            account,
+				"stateRoot":        blockHash,
+				"transactionsRoot": blockHash,
+				"receiptsRoot":     blockHash,
+				"logsBloom":        "0x" + strings.Repeat("00", 256),
+				"difficulty":       "0x0",
+				"number":           n.String(),
+				"gasLimit":         "0x0",
+				"gasUsed":          "0x0",
+				"timestamp":        "0x0",
+				"extraData":        "0x",
+			}, nil
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second, PollInterval: 10 * time.Millisecond}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	ch := make(chan *types.Header)
+	sub := c.SubscribeHeads(context.Background(), ch)
+	defer sub.Unsubscribe()
+
+	// receive continuous headers from polling
+	var last uint64
+	for i := 0; i < 3; i++ {
+		select {
+		case header := <-ch:
+			if last != 0 && header.Number.Uint64() != last+1 {
+				t.Errorf("Expect header %d but %d", last+1, header.Number.Uint64())
+				return
+			}
+			last = header.Number.Uint64()
+		case <-time.After(time.Second):
+			t.Errorf("Wait header timeout with error: %v", sub.Err())
+			return
+		}
+	}
+}
+
+func TestSubscribeLogs(t *testing.T) {
+	number := uint64(10)
+	lock := sync.Mutex{}
+
+	// one log each block and block number increases on each query
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch method {
+		case "eth_blockNumber":
+			number++
+			return hexutil.EncodeUint64(number), nil
+		case "eth_getLogs":
+			var crit struct {
+				FromBlock hexutil.Uint64 `json:"fromBlock"`
+				ToBlock   hexutil.Uint64 `json:"toBlock"`
+			}
+			json.Unmarshal(params[0], &crit)
+
+			logs := make([]types.Log, 0)
+			for n := uint64(crit.FromBlock); n <= uint64(crit.ToBlock); n++ {
+				logs = append(logs, newTestLog(n))
+			}
+			return logs, nil
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second, PollInterval: 10 * time.Millisecond}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	ch := make(chan types.Log)
+	sub := c.SubscribeLogs(context.Background(), ethereum.FilterQuery{Addresses: []common.Address{common.HexToAddress(contract)}}, ch)
+	defer sub.Unsubscribe()
+
+	// receive logs of continuous blocks from polling
+	var last uint64
+	for i := 0; i < 3; i++ {
+		select {
+		case log := <-ch:
+			if last != 0 && log.BlockNumber != last+1 {
+				t.Errorf("Expect log of block %d but %d", last+1, log.BlockNumber)
+				return
+			}
+			last = log.BlockNumber
+		case <-time.After(time.Second):
+			t.Errorf("Wait log timeout with error: %v", sub.Err())
+			return
+		}
+	}
+}
+
+// websocket node mines blocks with one log each
+type logService struct {
+	lock sync.Mutex
+	head uint64
+}
+
+func (s *logService) mine() types.Log {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.head++
+	return newTestLog(s.head)
+}
+
+func (s *logService) BlockNumber() hexutil.Uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return hexutil.Uint64(s.head)
+}
+
+func (s *logService) GetLogs(crit struct {
+	FromBlock hexutil.Uint64 `json:"fromBlock"`
+	ToBlock   hexutil.Uint64 `json:"toBlock"`
+}) []types.Log {
+	logs := make([]types.Log, 0)
+	for n := uint64(crit.FromBlock); n <= uint64(crit.ToBlock) && n <= uint64(s.BlockNumber()); n++ {
+		logs = append(logs, newTestLog(n))
+	}
+
+	return logs
+}
+
+func (s *logService) Logs(ctx context.Context, crit map[string]interface{}) (*rpc.Subscription, error) {
+	notifier, _ := rpc.NotifierFromContext(ctx)
+	sub := notifier.CreateSubscription()
+
+	// block 11 is mined without notification while subscribing, block 12 is notified after backfill and block 13 later
+	s.mine()
+	log := s.mine()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		notifier.Notify(sub.ID, log)
+		notifier.Notify(sub.ID, s.mine())
+	}()
+
+	return sub, nil
+}
+
+func newTestLog(number uint64) types.Log {
+	return types.Log{
+		Address:     common.HexToAddress(contract),
+		Topics:      []common.Hash{common.HexToHash(txHash)},
+		Data:        []byte{},
+		BlockNumber: number,
+		BlockHash:   common.BigToHash(new(big.Int).SetUint64(number)),
+		TxHash:      common.HexToHash(txHash),
+	}
+}
+
+func TestSubscribeLogsWs(t *testing.T) {
+	srv := rpc.NewServer()
+	srv.RegisterName("eth", &logService{head: 10})
+	defer srv.Stop()
+
+	server := httptest.NewServer(srv.WebsocketHandler([]string{"*"}))
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{"ws://" + strings.TrimPrefix(server.URL, "http://")}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	ch := make(chan types.Log)
+	sub := c.SubscribeLogs(context.Background(), ethereum.FilterQuery{Addresses: []common.Address{common.HexToAddress(contract)}}, ch)
+	defer sub.Unsubscribe()
+
+	// the log mined while subscribing is backfilled and the duplicated one is dropped
+	for _, expect := range []uint64{10, 11, 12, 13} {
+		select {
+		case log := <-ch:
+			if log.BlockNumber != expect {
+				t.Errorf("Expect log of block %d but %d", expect, log.BlockNumber)
+				return
+			}
+		case <-time.After(time.Second):
+			t.Errorf("Wait log of block %d timeout with error: %v", expect, sub.Err())
+			return
+		}
+	}
+
+	select {
+	case log := <-ch:
+		t.Errorf("Unexpected log of block %d", log.BlockNumber)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// handler records handled blocks and rollback
+type recordHandler struct {
+	blocks   []uint64
+	rollback []uint64
+}
+
+func (h *recordHandler) HandleBlock(ctx context.Context, block *types.Block) error {
+	h.blocks = append(h.blocks, block.NumberU64())
+	return nil
+}
+
+func (h *recordHandler) Rollback(ctx context.Context, number uint64) error {
+	h.rollback = append(h.rollback, number)
+	return nil
+}
+
+// handler writes each block to database and fails on given block
+type failHandler struct {
+	db   *database.Database
+	fail uint64
+}
+
+func (h *failHandler) HandleBlock(ctx context.Context, block *types.Block) error {
+	_, err := h.db.ExecSql("insert into handled_blocks(number) values(?);", int64(block.NumberU64()))
+	if err != nil {
+		return err
+	}
+
+	if block.NumberU64() == h.fail {
+		return errors.New("handle failed")
+	}
+
+	return nil
+}
+
+func (h *failHandler) Rollback(ctx context.Context, number uint64) error {
+	_, err := h.db.ExecSql("delete from handled_blocks where number >= ?;", int64(number))
+	return err
+}
+
+func TestScanner(t *testing.T) {
+	// build chain of empty blocks, fork with different extra data
+	build := func(headers []*types.Header, from int, to int, extra byte) []*types.Header {
+		for i := from; i <= to; i++ {
+			header := &types.Header{
+				Number:      big.NewInt(int64(i)),
+				Difficulty:  common.Big0,
+				Extra:       []byte{extra},
+				TxHash:      types.EmptyRootHash,
+				UncleHash:   types.EmptyUncleHash,
+				ReceiptHash: types.EmptyRootHash,
+			}
+			if i > 0 {
+				header.ParentHash = headers[i-1].Hash()
+			}
+			headers = append(headers[:i], header)
+		}
+		return headers
+	}
+
+	lock := sync.Mutex{}
+	headers := build(make([]*types.Header, 0), 0, 5, 0)
+
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch method {
+		case "eth_blockNumber":
+			return hexutil.EncodeUint64(uint64(len(headers) - 1)), nil
+		case "eth_getBlockByNumber":
+			var n hexutil.Uint64
+			json.Unmarshal(params[0], &n)
+			if int(n) >= len(headers) {
+				return nil, nil
+			}
+
+			data, _ := json.Marshal(headers[n])
+			block := make(map[string]interface{})
+			json.Unmarshal(data, &block)
+			block["transactions"] = []interface{}{}
+			block["uncles"] = []interface{}{}
+			return block, nil
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	os.Remove(dbfile)
+	db := database.NewDatabase(dbfile)
+	db.Open()
+	defer os.Remove(dbfile)
+	defer db.Close()
+
+	scanner, err := chain.NewScanner(c, db, "test")
+	if err != nil {
+		t.Errorf("Create scanner failed with error: %v", err)
+		return
+	}
+
+	handler := &recordHandler{}
+	scanner.AddHandler(handler)
+
+	scan := func() {
+		for {
+			scanned, err := scanner.Step(context.Background())
+			if err != nil {
+				t.Errorf("Scan failed with error: %v", err)
+				return
+			}
+			if !scanned {
+				return
+			}
+		}
+	}
+
+	scan()
+	number, _, _ := scanner.Checkpoint()
+	if number != 5 || len(handler.blocks) != 6 {
+		t.Errorf("Expect checkpoint 5 and 6 blocks but %d %v", number, handler.blocks)
+		return
+	}
+
+	// reorg from block 3 and grow to 6
+	lock.Lock()
+	headers = build(headers, 3, 6, 1)
+	lock.Unlock()
+
+	scan()
+	number, _, _ = scanner.Checkpoint()
+	if number != 6 || len(handler.rollback) != 1 || handler.rollback[0] != 3 {
+		t.Errorf("Expect rollback from 3 but %v", handler.rollback)
+		return
+	}
+
+	if fmt.Sprint(handler.blocks[6:]) != "[3 4 5 6]" {
+		t.Errorf("Expect rescan blocks 3 to 6 but %v", handler.blocks[6:])
+		return
+	}
+
+	// failed block leaves neither handler data nor checkpoint
+	db.ExecSql("create table if not exists handled_blocks(number integer);")
+	scanner.AddHandler(&failHandler{db: db, fail: 7})
+	lock.Lock()
+	headers = build(headers, 7, 7, 1)
+	lock.Unlock()
+
+	_, err = scanner.Step(context.Background())
+	if err == nil {
+		t.Errorf("Expect handler error")
+		return
+	}
+
+	number, _, _ = scanner.Checkpoint()
+	rows, _ := db.Query("select number from handled_blocks;")
+	if number != 6 || len(rows) != 0 {
+		t.Errorf("Expect checkpoint 6 and no handled block but %d %v", number, rows)
+	}
+}
+
+func TestBtcChain(t *testing.T) {
+	var outputs []map[string]json.Number
+	var inputs []map[string]interface{}
+
+	// bitcoind stand-in with two utxos of 0.5 and 0.1 btc
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return 100, nil
+		case "estimatesmartfee":
+			return map[string]interface{}{"feerate": json.Number("0.00002"), "blocks": 6}, nil
+		case "scantxoutset":
+			return map[string]interface{}{
+				"success": true,
+				"unspents": []interface{}{
+					map[string]interface{}{"txid": "aa", "vout": 0, "scriptPubKey": "0014", "amount": json.Number("0.1"), "height": 90},
+					map[string]interface{}{"txid": "bb", "vout": 1, "scriptPubKey": "0014", "amount": json.Number("0.5"), "height": 91},
+				},
+				"total_amount": json.Number("0.6"),
+			}, nil
+		case "createrawtransaction":
+			json.Unmarshal(params[0], &inputs)
+			d := json.NewDecoder(strings.NewReader(string(params[1])))
+			d.UseNumber()
+			d.Decode(&outputs)
+			return "rawtx", nil
+		case "signrawtransactionwithkey":
+			return map[string]interface{}{"hex": "signedtx", "complete": true}, nil
+		case "sendrawtransaction":
+			return "txid", nil
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	c := &chain.BtcChain{Id: chain.BTC_REGTEST, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	balance, err := c.Balance(context.Background(), "bcrt1qaddress")
+	if err != nil || balance.Int64() != 60000000 {
+		t.Errorf("Expect balance 60000000 but %v %v", balance, err)
+		return
+	}
+
+	// 2 sat/vB from 0.00002 btc/kvB
+	rate, _ := c.GasPrice(context.Background())
+	if rate.Int64() != 2 {
+		t.Errorf("Expect fee rate 2 but %v", rate)
+		return
+	}
+
+	// the largest utxo is enough, change back to sender
+	w := wallet.NewWallet(wallet.WALLET_BTC, "", "")
+	w.(*wallet.BtcWallet).SetAddress("bcrt1qaddress")
+	txid, err := c.Transfer(context.Background(), "bcrt1qreceiver", big.NewInt(20000000), w)
+	if err != nil || txid != "txid" {
+		t.Errorf("Transfer failed with error: %v", err)
+		return
+	}
+
+	fee := int64(2 * (11 + 68 + 31*2))
+	if len(inputs) != 1 || inputs[0]["txid"] != "bb" || len(outputs) != 2 {
+		t.Errorf("Selected coins not expected %v %v", inputs, outputs)
+		return
+	}
+
+	change, _ := helper.ParseAmount(outputs[1]["bcrt1qaddress"].String(), 8)
+	if outputs[0]["bcrt1qreceiver"] != "0.2" || change.Int64() != 30000000-fee {
+		t.Errorf("Outputs not expected %v", outputs)
+	}
+
+	// not enough balance
+	_, err = c.Transfer(context.Background(), "bcrt1qreceiver", big.NewInt(60000000), w)
+	if err == nil {
+		t.Errorf("Expect insufficient balance but nil")
+	}
+}
+
+func TestBtcModel(t *testing.T) {
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return 101, nil
+		case "getblockhash":
+			return "00aa", nil
+		case "getblock":
+			return map[string]interface{}{"hash": "00aa", "height": 100, "time": 1650000000, "previousblockhash": "0099", "tx": []string{"t1", "t2"}}, nil
+		case "getblockheader":
+			return map[string]interface{}{"hash": "00aa", "height": 100}, nil
+		case "getrawtransaction":
+			return map[string]interface{}{
+				"txid":          "t1",
+				"blockhash":     "00aa",
+				"confirmations": 2,
+				"fee":           json.Number("0.0000141"),
+				"vout": []interface{}{
+					map[string]interface{}{"value": json.Number("0.2"), "n": 0, "scriptPubKey": map[string]interface{}{"address": "bcrt1qreceiver"}},
+					map[string]interface{}{"value": json.Number("0.0999859"), "n": 1, "scriptPubKey": map[string]interface{}{"address": "bcrt1qaddress"}},
+				},
+			}, nil
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	var c chain.Chain = &chain.BtcChain{Id: chain.BTC_REGTEST, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	b, err := c.BlockByNumber(context.Background(), 100)
+	if err != nil || b.Number != 100 || b.ParentHash != "0099" || len(b.Transactions) != 2 || b.Btc() == nil || b.Eth() != nil {
+		t.Errorf("Block not expected %v %v", b, err)
+		return
+	}
+
+	tx, err := c.Transaction(context.Background(), "t1")
+	if err != nil || tx.Pending || tx.To != "bcrt1qreceiver" || tx.Value.Int64() != 29998590 {
+		t.Errorf("Transaction not expected %v %v", tx, err)
+		return
+	}
+
+	r, err := c.Receipt(context.Background(), "t1")
+	if err != nil || !r.Success || r.BlockNumber != 100 || r.Fee.Int64() != 1410 {
+		t.Errorf("Receipt not expected %v %v", r, err)
+	}
+}
+
+func TestNewChainByType(t *testing.T) {
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		switch method {
+		case "eth_chainId":
+			return "0x89", nil
+		case "eth_blockNumber":
+			return "0x10", nil
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	// new evm chain only need config
+	list := fmt.Sprintf(`[
+		{"Id": 137, "Type": "evm", "Name": "polygon-test", "Currency": "MATIC", "RpcServer": ["%s"]},
+		{"Id": 99999, "Type": "unknown", "Name": "unknown-test", "Currency": "UNK", "RpcServer": ["%s"]}
+	]`, server.URL, server.URL)
+
+	path := "./chainlist_type.json"
+	err := os.WriteFile(path, []byte(list), 0666)
+	if err != nil {
+		t.Errorf("Write chain list failed with err %v", err)
+		return
+	}
+	defer os.Remove(path)
+
+	err = chain.LoadChainList(path)
+	if err != nil {
+		t.Errorf("Load chain meta info failed with err %v", err)
+		return
+	}
+
+	c, err := chain.NewChain(context.Background(), chain.POLYGON_MAINNET, "MATIC", "polygon-test")
+	if err != nil {
+		t.Errorf("New evm chain failed with err %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	if _, ok := c.(*chain.EthChain); !ok {
+		t.Errorf("Expect evm chain but %T", c)
+		return
+	}
+
+	_, err = chain.NewChain(context.Background(), 99999, "UNK", "unknown-test")
+	if err == nil {
+		t.Errorf("Expect error of unknown chain type but nil")
+	}
+}
+
+func TestFeeEstimator(t *testing.T) {
+	word := func(v int64) string {
+		return common.Bytes2Hex(common.LeftPadBytes(big.NewInt(v).Bytes(), 32))
+	}
+
+	// oracle returns L1 fee 1000 wei, node interface returns 500 L1 gas
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		switch method {
+		case "eth_blockNumber":
+			return "0x10", nil
+		case "eth_call":
+			var msg struct {
+				To string `json:"to"`
+			}
+			json.Unmarshal(params[0], &msg)
+
+			switch strings.ToLower(msg.To) {
+			case strings.ToLower(chain.OP_GAS_PRICE_ORACLE):
+				return "0x" + word(1000), nil
+			case strings.ToLower(chain.ARBITRUM_NODE_INTERFACE):
+				return "0x" + word(500) + word(100) + word(30), nil
+			}
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: chain.OPTIMISM_MAINNET, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	to := common.HexToAddress(account)
+	tx := c.NewTransaction(0, &to, big.NewInt(1), 21000, &chain.Fee{GasPrice: big.NewInt(10)}, nil)
+
+	cases := []struct {
+		rollup string
+		gas    uint64
+		l1fee  int64
+		total  int64
+	}{
+		{chain.ROLLUP_NONE, 21000, 0, 210000},
+		{chain.ROLLUP_OPSTACK, 21000, 1000, 211000},
+		{chain.ROLLUP_ARBITRUM, 21500, 5000, 215000},
+	}
+
+	for _, e := range cases {
+		c.Estimator, err = chain.NewFeeEstimator(e.rollup)
+		if err != nil {
+			t.Errorf("New estimator %s failed with error: %v", e.rollup, err)
+			return
+		}
+
+		cost, err := c.EstimateCost(context.Background(), tx)
+		if err != nil {
+			t.Errorf("Estimate cost on %s failed with error: %v", e.rollup, err)
+			continue
+		}
+
+		if cost.GasLimit != e.gas || cost.L1Fee.Int64() != e.l1fee || cost.Total.Int64() != e.total {
+			t.Errorf("Cost on %s expect %d %d %d but %d %v %v", e.rollup, e.gas, e.l1fee, e.total, cost.GasLimit, cost.L1Fee, cost.Total)
+		}
+	}
+
+	_, err = chain.NewFeeEstimator("zksync")
+	if err == nil {
+		t.Errorf("Expect error of unknown rollup but nil")
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	receiver := "0x0000000000000000000000000000000000000002"
+	revert := false
+
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		switch method {
+		case "eth_blockNumber":
+			return "0x10", nil
+		case "eth_call":
+			var block string
+			json.Unmarshal(params[1], &block)
+			if block != "pending" {
+				return nil, errors.New("expect pending block")
+			}
+
+			if revert {
+				return nil, errors.New("execution reverted: nope")
+			}
+			return "0x", nil
+		case "eth_estimateGas":
+			return "0x5208", nil
+		case "eth_gasPrice":
+			return "0xa", nil
+		case "eth_getBalance":
+			var address string
+			json.Unmarshal(params[0], &address)
+			if strings.EqualFold(address, receiver) {
+				return "0x0", nil
+			}
+			return "0xf4240", nil
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: chain.DEV_NETWORK, Currency: "ETH", Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	key, _ := crypto.GenerateKey()
+	w := wallet.NewWallet(wallet.WALLET_ETH, "", "")
+	w.SetPrivateKey(common.Bytes2Hex(crypto.FromECDSA(key)))
+
+	// fee is 21000 * 10, sender pays value 1000 and fee
+	sim, err := c.DryRunTransfer(context.Background(), receiver, big.NewInt(1000), w)
+	if err != nil || !sim.Success || sim.Gas != 21000 || sim.Cost.Total.Int64() != 210000 || len(sim.Changes) != 2 {
+		t.Errorf("Simulation not expected %v %v", sim, err)
+		return
+	}
+
+	if sim.Changes[0].After.Int64() != 1000000-1000-210000 || sim.Changes[1].After.Int64() != 1000 {
+		t.Errorf("Balance changes not expected %v", sim)
+		return
+	}
+
+	// value more than balance
+	sim, err = c.DryRunTransfer(context.Background(), receiver, big.NewInt(900000), w)
+	if err != nil || sim.Success {
+		t.Errorf("Expect insufficient balance but %v %v", sim, err)
+		return
+	}
+
+	revert = true
+	sim, err = c.DryRunTransfer(context.Background(), receiver, big.NewInt(1000), w)
+	if err != nil || sim.Success || !strings.Contains(sim.Reason, "nope") {
+		t.Errorf("Expect reverted simulation but %v %v", sim, err)
+	}
+}
@@ -0,0 +1,874 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"utopia/contracts/token"
+	"utopia/internal/chain"
+	contracts "utopia/internal/contract"
+	"utopia/internal/database"
+	"utopia/internal/wallet"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const multicall3ABI = `[{"inputs":[{"components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}],"name":"calls","type":"tuple[]"}],"name":"aggregate3","outputs":[{"components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}],"name":"returnData","type":"tuple[]"}],"stateMutability":"payable","type":"function"}]`
+
+func TestMulticall(t *testing.T) {
+	parsed, _ := abi.JSON(strings.NewReader(multicall3ABI))
+	erc20, _ := token.ERC20MetaData.GetAbi()
+
+	type call3 struct {
+		Target       common.Address
+		AllowFailure bool
+		CallData     []byte
+	}
+
+	type result3 struct {
+		Success    bool
+		ReturnData []byte
+	}
+
+	// the second token call is reverted
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		if method != "eth_call" {
+			return nil, errors.New("method not found")
+		}
+
+		var msg struct {
+			Data hexutil.Bytes `json:"data"`
+		}
+		json.Unmarshal(params[0], &msg)
+
+		args, err := parsed.Methods["aggregate3"].Inputs.Unpack(msg.Data[4:])
+		if err != nil {
+			return nil, err
+		}
+
+		var calls []call3
+		abi.ConvertType(args[0], &calls)
+
+		results := make([]result3, 0, len(calls))
+		for i := range calls {
+			if i == 1 {
+				results = append(results, result3{Success: false})
+				continue
+			}
+
+			data, _ := erc20.Methods["balanceOf"].Outputs.Pack(big.NewInt(int64(i + 100)))
+			results = append(results, result3{Success: true, ReturnData: data})
+		}
+
+		data, _ := parsed.Methods["aggregate3"].Outputs.Pack(results)
+		return hexutil.Encode(data), nil
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	m, err := contracts.NewMulticallAt(c, contracts.MULTICALL3_ADDRESS, false)
+	if err != nil {
+		t.Errorf("Create multicall failed with error: %v", err)
+		return
+	}
+
+	calls := make([]*contracts.MultiCall, 0)
+	for i := 0; i < 3; i++ {
+		calls = append(calls, &contracts.MultiCall{Target: contract, ABI: erc20, Method: "balanceOf", Args: []interface{}{common.HexToAddress(account)}})
+	}
+
+	// invalid args failed without sending
+	calls = append(calls, &contracts.MultiCall{Target: contract, ABI: erc20, Method: "balanceOf", Args: []interface{}{"x"}})
+
+	results, err := m.Call(context.Background(), calls)
+	if err != nil {
+		t.Errorf("Multicall failed with error: %v", err)
+		return
+	}
+
+	if !results[0].Success || results[0].Values[0].(*big.Int).Int64() != 100 || results[1].Success || results[1].Err == nil {
+		t.Errorf("Multicall results not expected")
+	}
+
+	if !results[2].Success || results[2].Values[0].(*big.Int).Int64() != 102 || results[3].Success {
+		t.Errorf("Multicall results not expected")
+	}
+}
+
+func TestDecodeLog(t *testing.T) {
+	erc20, _ := token.ERC20MetaData.GetAbi()
+	event := erc20.Events["Transfer"]
+	data, _ := event.Inputs.NonIndexed().Pack(big.NewInt(1000))
+
+	log := types.Log{
+		Address: common.HexToAddress(contract),
+		Topics: []common.Hash{
+			event.ID,
+			common.BytesToHash(common.HexToAddress(account).Bytes()),
+			common.BytesToHash(common.HexToAddress(contract).Bytes()),
+		},
+		Data: data,
+	}
+
+	e, err := contracts.DecodeLog(erc20, log)
+	if err != nil {
+		t.Errorf("Decode log failed with error: %v", err)
+		return
+	}
+
+	if e.Name != "Transfer" || e.Args["from"].(common.Address).Hex() != account || e.Args["value"].(*big.Int).Int64() != 1000 {
+		t.Errorf("Decoded event not expected %s", e)
+	}
+
+	if e.ArgsText() != "from="+account+",to="+common.HexToAddress(contract).Hex()+",value=1000" {
+		t.Errorf("Event args not expected %s", e.ArgsText())
+	}
+
+	// array and tuple are formatted same as call result
+	parsed, _ := abi.JSON(strings.NewReader(`[{"anonymous":false,"inputs":[{"indexed":false,"name":"list","type":"uint8[]"},{"components":[{"name":"x","type":"uint64"},{"name":"y","type":"string"}],"indexed":false,"name":"f","type":"tuple"}],"name":"Packed","type":"event"}]`))
+	packed, _ := parsed.Events["Packed"].Inputs.Pack([]uint8{1, 2}, struct {
+		X uint64
+		Y string
+	}{7, "seven"})
+	e, err = contracts.DecodeLog(&parsed, types.Log{Topics: []common.Hash{parsed.Events["Packed"].ID}, Data: packed})
+	if err != nil || e.ArgsText() != "list=[1,2],f=(7,seven)" {
+		t.Errorf("Event args not expected %s, error: %v", e.ArgsText(), err)
+	}
+
+	// unknown event keeps raw log
+	log.Topics[0] = common.Hash{1}
+	e, _ = contracts.DecodeLog(erc20, log)
+	if e.Name != contracts.UNKNOWN_EVENT {
+		t.Errorf("Expect unknown event but %s", e.Name)
+	}
+}
+
+const resultABI = `[{"inputs":[],"name":"get","outputs":[{"name":"a","type":"uint256"},{"name":"b","type":"address"},{"name":"c","type":"bool"},{"name":"d","type":"bytes32"},{"name":"e","type":"uint8[]"},{"components":[{"name":"x","type":"uint64"},{"name":"y","type":"string"}],"name":"f","type":"tuple"}],"stateMutability":"view","type":"function"}]`
+
+func TestCallResult(t *testing.T) {
+	parsed, _ := abi.JSON(strings.NewReader(resultABI))
+	output, err := parsed.Methods["get"].Outputs.Pack(big.NewInt(42), common.HexToAddress(account), true, [32]byte{1}, []uint8{1, 2},
+		struct {
+			X uint64
+			Y string
+		}{7, "seven"})
+	if err != nil {
+		t.Errorf("Pack outputs failed with error: %v", err)
+		return
+	}
+
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		switch method {
+		case "eth_blockNumber":
+			return "0x10", nil
+		case "eth_call":
+			return hexutil.Encode(output), nil
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second}
+	err = c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	path := "./result.abi"
+	ioutil.WriteFile(path, []byte(resultABI), 0666)
+	defer os.Remove(path)
+
+	contract := contracts.NewContract(c, "0x5FbDB2315678afecb367f032d93F642f64180aa3", contracts.COMMON_CRONTACT)
+	err = contract.SetABI(path)
+	if err != nil {
+		t.Errorf("Set abi failed with error: %v", err)
+		return
+	}
+
+	result, err := contract.Call(context.Background(), "get()", nil, nil)
+	if err != nil {
+		t.Errorf("Call contract failed with error: %v", err)
+		return
+	}
+
+	expect := []string{"42", common.HexToAddress(account).Hex(), "true", "0x01" + strings.Repeat("00", 31), "[1,2]", "(7,seven)"}
+	if len(result.Outputs) != len(expect) {
+		t.Errorf("Expect %d outputs but %d", len(expect), len(result.Outputs))
+		return
+	}
+
+	for i, output := range result.Outputs {
+		if output.Value != expect[i] {
+			t.Errorf("Output %s expect %s but %s", output.Name, expect[i], output.Value)
+		}
+	}
+
+	text, err := result.JSON()
+	if err != nil || !strings.Contains(text, `"value": "(7,seven)"`) {
+		t.Errorf("Json result not expected %s %v", text, err)
+	}
+}
+
+func TestEncodeABI(t *testing.T) {
+	contract := contracts.NewContract(nil, "", contracts.COMMON_CRONTACT)
+	method := "multiCall((address,bytes)[],uint)"
+	data := "multiCall([(0x5FbDB2315678afecb367f032d93F642f64180aa3,0x1234),(0x5FbDB2315678afecb367f032d93F642f64180aa3,0x)],-0)"
+
+	encoded, err := contract.EncodeABI(method, data, true)
+	if err != nil {
+		t.Errorf("Encode abi failed with error: %v", err)
+		return
+	}
+
+	if !strings.HasPrefix(encoded, hexutil.Encode(crypto.Keccak256([]byte("multiCall((address,bytes)[],uint256)"))[:4])) {
+		t.Errorf("Not match function selector %s", encoded)
+	}
+
+	decoded, err := contract.DecodeABI(method, encoded, true)
+	expect := "multiCall([(0x5FbDB2315678afecb367f032d93F642f64180aa3,0x1234),(0x5FbDB2315678afecb367f032d93F642f64180aa3,0x)],0)"
+	if err != nil || decoded != expect {
+		t.Errorf("Decode abi expect %s but %s, error: %v", expect, decoded, err)
+	}
+}
+
+func TestSelectorDB(t *testing.T) {
+	path := "./selector.db"
+	os.Remove(path)
+	defer os.Remove(path)
+
+	db := database.NewDatabase(path)
+	err := db.Open()
+	if err != nil {
+		t.Errorf("Open database failed with error: %v", err)
+		return
+	}
+	defer db.Close()
+
+	selectors, err := contracts.NewSelectorDB(db)
+	if err != nil {
+		t.Errorf("Create selector database failed with error: %v", err)
+		return
+	}
+
+	// erc20 transfer is built-in
+	erc20, _ := token.ERC20MetaData.GetAbi()
+	data, _ := erc20.Pack("transfer", common.HexToAddress(account), big.NewInt(100))
+	result, err := selectors.Decode(data)
+	expect := fmt.Sprintf("transfer(%s,100)", common.HexToAddress(account).Hex())
+	if err != nil || result != expect {
+		t.Errorf("Decode expect %s but %s, error: %v", expect, result, err)
+	}
+
+	// erc20 permit extension is built-in
+	permit, _ := token.ERC20PermitMetaData.GetAbi()
+	data, _ = permit.Pack("nonces", common.HexToAddress(account))
+	result, err = selectors.Decode(data)
+	expect = fmt.Sprintf("nonces(%s)", common.HexToAddress(account).Hex())
+	if err != nil || result != expect {
+		t.Errorf("Decode expect %s but %s, error: %v", expect, result, err)
+	}
+
+	// import signature list with selector check
+	list := "./signatures.txt"
+	os.WriteFile(list, []byte("# signatures\nsetApprovalForAll(address, bool)\n0x1cff79cd execute(address,bytes)\n"), 0666)
+	defer os.Remove(list)
+
+	count, err := selectors.ImportFile(list)
+	if err != nil || count != 1 {
+		t.Errorf("Import signatures count %d, error: %v", count, err)
+	}
+
+	os.WriteFile(list, []byte("0x12345678 execute(address,bytes)\n"), 0666)
+	if _, err = selectors.ImportFile(list); err == nil {
+		t.Errorf("Expect error of wrong selector")
+	}
+
+	_, err = selectors.Decode(common.FromHex("0xdeadbeef"))
+	if err == nil {
+		t.Errorf("Expect error of unknown selector")
+	}
+}
+
+func TestERC20(t *testing.T) {
+	erc20, _ := token.ERC20MetaData.GetAbi()
+	outputs := map[string][]interface{}{
+		"name":        {"USD Coin"},
+		"symbol":      {"USDC"},
+		"decimals":    {uint8(6)},
+		"totalSupply": {big.NewInt(1234500000)},
+		"balanceOf":   {big.NewInt(1500000)},
+		"allowance":   {big.NewInt(250000)},
+	}
+
+	// reply token calls by method selector
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		if method != "eth_call" {
+			return nil, errors.New("method not found")
+		}
+
+		var msg struct {
+			Data hexutil.Bytes `json:"data"`
+		}
+		json.Unmarshal(params[0], &msg)
+
+		m, err := erc20.MethodById(msg.Data[:4])
+		if err != nil {
+			return nil, err
+		}
+
+		data, _ := m.Outputs.Pack(outputs[m.Name]...)
+		return hexutil.Encode(data), nil
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	usdc := contracts.NewContract(c, "0x5FbDB2315678afecb367f032d93F642f64180aa3", contracts.ERC20_CONTRACT).(*contracts.ERC20Contract)
+	info, err := usdc.Info(context.Background())
+	if err != nil || info.Name != "USD Coin" || info.Symbol != "USDC" || info.Decimals != 6 || info.TotalSupply.Int64() != 1234500000 {
+		t.Errorf("Token info not expected %v, error: %v", info, err)
+		return
+	}
+
+	balance, _ := usdc.Balance(context.Background(), account)
+	amount, err := usdc.FormatAmount(context.Background(), balance)
+	if err != nil || amount != "1.5" {
+		t.Errorf("Balance expect 1.5 but %s, error: %v", amount, err)
+	}
+
+	allowance, err := usdc.Allowance(context.Background(), account, account)
+	if err != nil || allowance.Int64() != 250000 {
+		t.Errorf("Allowance expect 250000 but %v, error: %v", allowance, err)
+	}
+
+	value, err := usdc.ParseAmount(context.Background(), "0.000001")
+	if err != nil || value.Int64() != 1 {
+		t.Errorf("Parse amount expect 1 but %v, error: %v", value, err)
+	}
+
+	if _, err = usdc.ParseAmount(context.Background(), "0.0000001"); err == nil {
+		t.Errorf("Expect error of too many decimal places")
+	}
+}
+
+func TestERC721Inventory(t *testing.T) {
+	erc721, _ := token.ERC721MetaData.GetAbi()
+	enumerable, _ := abi.JSON(strings.NewReader(`[{"inputs":[{"name":"interfaceId","type":"bytes4"}],"name":"supportsInterface","outputs":[{"name":"","type":"bool"}],"stateMutability":"view","type":"function"},{"inputs":[{"name":"owner","type":"address"},{"name":"index","type":"uint256"}],"name":"tokenOfOwnerByIndex","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`))
+	nft := "0x5FbDB2315678afecb367f032d93F642f64180aa3"
+	owner := common.HexToAddress(account)
+	other := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e17d3B2F0aA")
+	supported := true
+	queries := 0
+
+	// token 1 and 3 received by account, token 3 transferred out later
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		switch method {
+		case "eth_blockNumber":
+			return "0x10", nil
+		case "eth_getLogs":
+			queries++
+			logs := make([]interface{}, 0)
+			for i, tokenid := range []int64{1, 3, 1} {
+				logs = append(logs, map[string]interface{}{
+					"address":          nft,
+					"topics":           []common.Hash{erc721.Events["Transfer"].ID, {}, common.BytesToHash(owner.Bytes()), common.BigToHash(big.NewInt(tokenid))},
+					"data":             "0x",
+					"blockNumber":      hexutil.EncodeUint64(uint64(i + 1)),
+					"transactionHash":  common.Hash{}.Hex(),
+					"transactionIndex": "0x0",
+					"blockHash":        common.Hash{}.Hex(),
+					"logIndex":         "0x0",
+					"removed":          false,
+				})
+			}
+			return logs, nil
+		case "eth_call":
+			var msg struct {
+				Data hexutil.Bytes `json:"data"`
+			}
+			json.Unmarshal(params[0], &msg)
+
+			if m, err := enumerable.MethodById(msg.Data[:4]); err == nil {
+				if !supported {
+					return nil, errors.New("execution reverted")
+				}
+
+				args, _ := m.Inputs.Unpack(msg.Data[4:])
+				if m.Name == "supportsInterface" {
+					data, _ := m.Outputs.Pack(args[0].([4]byte) == [4]byte{0x78, 0x0e, 0x9d, 0x63})
+					return hexutil.Encode(data), nil
+				}
+
+				data, _ := m.Outputs.Pack(new(big.Int).Add(args[1].(*big.Int), big.NewInt(10)))
+				return hexutil.Encode(data), nil
+			}
+
+			m, err := erc721.MethodById(msg.Data[:4])
+			if err != nil {
+				return nil, err
+			}
+
+			args, _ := m.Inputs.Unpack(msg.Data[4:])
+			var data []byte
+			switch m.Name {
+			case "balanceOf":
+				data, _ = m.Outputs.Pack(big.NewInt(2))
+			case "ownerOf":
+				holder := owner
+				if args[0].(*big.Int).Cmp(big.NewInt(3)) == 0 {
+					holder = other
+				}
+				data, _ = m.Outputs.Pack(holder)
+			}
+			return hexutil.Encode(data), nil
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	// enumerable contract lists tokens by index
+	contract := contracts.NewContract(c, nft, contracts.ERC721_CONTRACT).(*contracts.ERC721Contract)
+	if contract.ABI() != token.ERC721ABI {
+		t.Errorf("Not erc721 abi")
+	}
+
+	tokenids, err := contract.TokensOf(context.Background(), account, 0)
+	if err != nil || len(tokenids) != 2 || tokenids[0].Int64() != 10 || tokenids[1].Int64() != 11 {
+		t.Errorf("Enumerable tokens not expected %v, error: %v", tokenids, err)
+	}
+
+	// not enumerable contract without ERC165 finds tokens by logs
+	supported = false
+	contract = contracts.NewContract(c, nft, contracts.ERC721_CONTRACT).(*contracts.ERC721Contract)
+	holdings, err := contract.Inventory(context.Background(), []string{account}, 0)
+	if err != nil || len(holdings) != 1 || len(holdings[0].TokenIds) != 1 || holdings[0].TokenIds[0].Int64() != 1 {
+		t.Errorf("Inventory not expected %v, error: %v", holdings, err)
+		return
+	}
+
+	// logs of all accounts are queried once and tokens grouped by owner
+	queries = 0
+	holdings, err = contract.Inventory(context.Background(), []string{account, other.Hex()}, 0)
+	if err != nil || len(holdings) != 2 || fmt.Sprint(holdings[0].TokenIds) != "[1]" || fmt.Sprint(holdings[1].TokenIds) != "[3]" || queries != 1 {
+		t.Errorf("Inventory of accounts not expected %v in %d queries, error: %v", holdings, queries, err)
+		return
+	}
+
+	// token id above uint64 is not truncated to 3
+	large := new(big.Int).Add(new(big.Int).Lsh(common.Big1, 64), big.NewInt(3))
+	holder, err := contract.Owner(context.Background(), large)
+	if err != nil || holder != owner.Hex() {
+		t.Errorf("Owner of large token id expect %s but %s, error: %v", owner.Hex(), holder, err)
+	}
+
+	// token call on btc chain fails without panic
+	btc := contracts.NewContract(&chain.BtcChain{Id: chain.BTC_REGTEST, Timeout: time.Second}, nft, contracts.ERC721_CONTRACT).(*contracts.ERC721Contract)
+	if _, err = btc.TokenIdByIndex(context.Background(), account, 0); err == nil {
+		t.Errorf("Expect error of token call on btc chain")
+	}
+
+	path := "./inventory.xlsx"
+	defer os.Remove(path)
+	err = contracts.SaveInventoryFile(nft, holdings, path)
+	if err != nil {
+		t.Errorf("Save inventory failed with error: %v", err)
+	}
+}
+
+func TestERC1155(t *testing.T) {
+	erc1155, _ := token.ERC1155MetaData.GetAbi()
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		if method != "eth_call" {
+			return nil, errors.New("method not found")
+		}
+
+		var msg struct {
+			Data hexutil.Bytes `json:"data"`
+		}
+		json.Unmarshal(params[0], &msg)
+
+		m, err := erc1155.MethodById(msg.Data[:4])
+		if err != nil {
+			return nil, err
+		}
+
+		args, _ := m.Inputs.Unpack(msg.Data[4:])
+		var data []byte
+		switch m.Name {
+		case "balanceOfBatch":
+			// balance is id * 10
+			balances := make([]*big.Int, 0)
+			for _, id := range args[1].([]*big.Int) {
+				balances = append(balances, new(big.Int).Mul(id, big.NewInt(10)))
+			}
+			data, _ = m.Outputs.Pack(balances)
+		case "uri":
+			data, _ = m.Outputs.Pack("ipfs://cid/{id}.json")
+		}
+		return hexutil.Encode(data), nil
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	contract := contracts.NewContract(c, "0x5FbDB2315678afecb367f032d93F642f64180aa3", contracts.ERC1155_CONTRACT).(*contracts.ERC1155Contract)
+	balances, err := contract.Balances(context.Background(), []string{account, account}, []*big.Int{big.NewInt(1), big.NewInt(2)})
+	if err != nil || len(balances) != 2 || balances[0].Int64() != 10 || balances[1].Int64() != 20 {
+		t.Errorf("Balances not expected %v, error: %v", balances, err)
+	}
+
+	if _, err = contract.Balances(context.Background(), []string{account}, []*big.Int{big.NewInt(1), big.NewInt(2)}); err == nil {
+		t.Errorf("Expect error of not matched accounts and ids")
+	}
+
+	uri, err := contract.TokenUrl(context.Background(), big.NewInt(314592))
+	expect := "ipfs://cid/000000000000000000000000000000000000000000000000000000000004cce0.json"
+	if err != nil || uri != expect {
+		t.Errorf("Uri expect %s but %s, error: %v", expect, uri, err)
+	}
+}
+
+func TestMetadataResolver(t *testing.T) {
+	path := "./metadata.db"
+	os.Remove(path)
+	defer os.Remove(path)
+
+	db := database.NewDatabase(path)
+	err := db.Open()
+	if err != nil {
+		t.Errorf("Open database failed with error: %v", err)
+		return
+	}
+	defer db.Close()
+
+	// first gateway hangs, second one is down and third one serves metadata
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hung.Close()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	requested := ""
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		fmt.Fprint(w, `{"name":"Token 1","image":"ipfs://image","attributes":[{"trait_type":"hat","value":"red"},{"trait_type":"level","value":3}]}`)
+	}))
+
+	resolver, err := contracts.NewMetadataResolver(db, []string{hung.URL + "/ipfs/", down.URL + "/ipfs/", up.URL + "/ipfs/"}, nil)
+	if err != nil {
+		t.Errorf("Create resolver failed with error: %v", err)
+		return
+	}
+	resolver.Timeout = 100 * time.Millisecond
+
+	// cancelled call stops without waiting gateway timeout
+	cctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = resolver.Resolve(cctx, "ipfs://ipfs/QmHash/1.json"); err == nil {
+		t.Errorf("Resolve with cancelled context should fail")
+	}
+
+	start := time.Now()
+	meta, err := resolver.Resolve(context.Background(), "ipfs://ipfs/QmHash/1.json")
+	if err != nil {
+		t.Errorf("Resolve metadata failed with error: %v", err)
+		return
+	}
+
+	if time.Since(start) > time.Second {
+		t.Errorf("Hung gateway not skipped after timeout")
+	}
+
+	if meta.Name != "Token 1" || requested != "/ipfs/QmHash/1.json" || meta.Attributes[1].ValueText() != "3" {
+		t.Errorf("Resolve metadata %v from %s", meta, requested)
+	}
+
+	// cached after gateway closed
+	up.Close()
+	_, err = resolver.Resolve(context.Background(), "ipfs://QmHash/1.json")
+	if err == nil {
+		t.Errorf("Resolve uncached metadata should fail")
+	}
+
+	meta, err = resolver.Resolve(context.Background(), "ipfs://ipfs/QmHash/1.json")
+	if err != nil || meta.Name != "Token 1" {
+		t.Errorf("Resolve cached metadata failed with error: %v", err)
+	}
+
+	// data uri is decoded without network
+	data := base64.StdEncoding.EncodeToString([]byte(`{"name":"Token 2","attributes":[{"trait_type":"hat","value":"blue"},{"trait_type":"level","value":3}]}`))
+	other, err := resolver.Resolve(context.Background(), "data:application/json;base64,"+data)
+	if err != nil || other.Name != "Token 2" {
+		t.Errorf("Resolve data uri failed with error: %v", err)
+		return
+	}
+
+	third := &contracts.ERC721Meta{Name: "Token 3", Attributes: []contracts.ERC721Attr{{TraitType: "hat", Value: "red"}, {TraitType: "level", Value: float64(3)}}}
+	tokens := []*contracts.TokenTraits{{TokenId: "1", Meta: meta}, {TokenId: "2", Meta: other}, {TokenId: "3", Meta: third}}
+	counts := contracts.RarityScores(tokens)
+	if counts["hat"]["red"] != 2 || counts["level"]["3"] != 3 {
+		t.Errorf("Count traits %v", counts)
+	}
+
+	if tokens[0].TokenId != "2" || tokens[0].Rank != 1 || tokens[2].Rank != 3 {
+		t.Errorf("Rank tokens %s %d", tokens[0].TokenId, tokens[0].Rank)
+	}
+
+	file := "./traits.xlsx"
+	defer os.Remove(file)
+	err = contracts.SaveTraitsFile(tokens, counts, file)
+	if err != nil {
+		t.Errorf("Save traits failed with error: %v", err)
+	}
+}
+
+func TestERC20Permit(t *testing.T) {
+	domain := crypto.Keccak256Hash([]byte("domain"))
+	nonce := big.NewInt(3)
+
+	// reply DOMAIN_SEPARATOR and nonces by selector
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		if method != "eth_call" {
+			return nil, errors.New("method not found")
+		}
+
+		var msg struct {
+			Data hexutil.Bytes `json:"data"`
+		}
+		json.Unmarshal(params[0], &msg)
+
+		switch hexutil.Encode(msg.Data[:4]) {
+		case hexutil.Encode(crypto.Keccak256([]byte("DOMAIN_SEPARATOR()"))[:4]):
+			return domain.Hex(), nil
+		case hexutil.Encode(crypto.Keccak256([]byte("nonces(address)"))[:4]):
+			return common.BigToHash(nonce).Hex(), nil
+		}
+
+		return nil, errors.New("execution reverted")
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	key, _ := crypto.GenerateKey()
+	owner := wallet.NewWallet(wallet.WALLET_ETH, "", "")
+	owner.SetPrivateKey(hexutil.Encode(crypto.FromECDSA(key)))
+
+	usdc := contracts.NewContract(c, "0x5FbDB2315678afecb367f032d93F642f64180aa3", contracts.ERC20_CONTRACT).(*contracts.ERC20Contract)
+	deadline := big.NewInt(time.Now().Unix() + 3600)
+	permit, err := usdc.SignPermit(context.Background(), account, big.NewInt(1000), deadline, owner)
+	if err != nil {
+		t.Errorf("Sign permit failed with error: %v", err)
+		return
+	}
+
+	if permit.Nonce.Cmp(nonce) != 0 || len(common.FromHex(permit.Signature)) != 65 {
+		t.Errorf("Permit not expected %v", permit)
+	}
+
+	err = usdc.VerifyPermit(context.Background(), permit)
+	if err != nil {
+		t.Errorf("Verify permit failed with error: %v", err)
+	}
+
+	// digest follows EIP-712 with the permit type hash
+	digest, _ := usdc.PermitDigest(context.Background(), permit)
+	structHash := crypto.Keccak256(
+		crypto.Keccak256([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)")),
+		common.LeftPadBytes(common.FromHex(owner.Address()), 32),
+		common.LeftPadBytes(common.FromHex(account), 32),
+		common.LeftPadBytes(big.NewInt(1000).Bytes(), 32),
+		common.LeftPadBytes(nonce.Bytes(), 32),
+		common.LeftPadBytes(deadline.Bytes(), 32),
+	)
+	if hexutil.Encode(digest) != hexutil.Encode(crypto.Keccak256([]byte{0x19, 0x01}, domain.Bytes(), structHash)) {
+		t.Errorf("Permit digest not expected %x", digest)
+	}
+
+	// tampered value, used nonce and expired deadline are rejected
+	tampered := *permit
+	tampered.Value = big.NewInt(2000)
+	if err = usdc.VerifyPermit(context.Background(), &tampered); err == nil {
+		t.Errorf("Expect error of tampered permit")
+	}
+
+	nonce = big.NewInt(4)
+	if err = usdc.VerifyPermit(context.Background(), permit); err == nil {
+		t.Errorf("Expect error of used nonce")
+	}
+
+	expired := *permit
+	expired.Deadline = big.NewInt(time.Now().Unix() - 1)
+	if err = usdc.VerifyPermit(context.Background(), &expired); err == nil {
+		t.Errorf("Expect error of expired permit")
+	}
+}
+
+func TestApprovals(t *testing.T) {
+	erc20, _ := token.ERC20MetaData.GetAbi()
+	erc721, _ := token.ERC721MetaData.GetAbi()
+	owner := common.HexToAddress(account)
+	usdc := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	nft := common.HexToAddress("0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512")
+	revoked := common.HexToAddress("0x9fE46736679d2D9a65F0992F2272dE9f3c7fa6e0")
+	spender := common.HexToAddress("0xCf7Ed3AccA5a467e9e704C703E8D87F634fB0Fc9")
+	spent := common.HexToAddress("0xDc64a140Aa3E981100a9becA4E685f962f0cF6C9")
+
+	// erc20 allowance, spent allowance, nft operator, single nft approval and revoked operator
+	events := []struct {
+		address common.Address
+		topics  []common.Hash
+		data    []byte
+	}{
+		{usdc, []common.Hash{erc20.Events["Approval"].ID, owner.Hash(), spender.Hash()}, common.BigToHash(big.NewInt(100)).Bytes()},
+		{usdc, []common.Hash{erc20.Events["Approval"].ID, owner.Hash(), spent.Hash()}, common.BigToHash(big.NewInt(100)).Bytes()},
+		{nft, []common.Hash{erc721.Events["ApprovalForAll"].ID, owner.Hash(), spender.Hash()}, common.BigToHash(common.Big1).Bytes()},
+		{nft, []common.Hash{erc721.Events["Approval"].ID, owner.Hash(), spent.Hash(), common.BigToHash(common.Big1)}, nil},
+		{revoked, []common.Hash{erc721.Events["ApprovalForAll"].ID, owner.Hash(), spender.Hash()}, common.BigToHash(common.Big1).Bytes()},
+	}
+
+	server := newRpcServer(func(method string, params []json.RawMessage) (interface{}, error) {
+		switch method {
+		case "eth_blockNumber":
+			return "0x10", nil
+		case "eth_getLogs":
+			logs := make([]interface{}, 0)
+			for i, event := range events {
+				logs = append(logs, map[string]interface{}{
+					"address":          event.address,
+					"topics":           event.topics,
+					"data":             hexutil.Encode(event.data),
+					"blockNumber":      hexutil.EncodeUint64(uint64(i + 1)),
+					"transactionHash":  common.Hash{}.Hex(),
+					"transactionIndex": "0x0",
+					"blockHash":        common.Hash{}.Hex(),
+					"logIndex":         "0x0",
+					"removed":          false,
+				})
+			}
+			return logs, nil
+		case "eth_call":
+			var msg struct {
+				To   common.Address `json:"to"`
+				Data hexutil.Bytes  `json:"data"`
+			}
+			json.Unmarshal(params[0], &msg)
+
+			var data []byte
+			if m, err := erc20.MethodById(msg.Data[:4]); err == nil && m.Name == "allowance" {
+				args, _ := m.Inputs.Unpack(msg.Data[4:])
+				allowance := big.NewInt(100)
+				if args[1].(common.Address) == spent {
+					allowance = big.NewInt(0)
+				}
+				data, _ = m.Outputs.Pack(allowance)
+			} else if m, err := erc721.MethodById(msg.Data[:4]); err == nil && m.Name == "isApprovedForAll" {
+				data, _ = m.Outputs.Pack(msg.To != revoked)
+			} else {
+				return nil, errors.New("execution reverted")
+			}
+			return hexutil.Encode(data), nil
+		}
+
+		return nil, errors.New("method not found")
+	})
+	defer server.Close()
+
+	c := &chain.EthChain{Id: 1, Timeout: time.Second}
+	err := c.Connect(context.Background(), []string{server.URL}, false)
+	if err != nil {
+		t.Errorf("Connect chain failed with error: %v", err)
+		return
+	}
+	defer c.DisConnect()
+
+	approvals, err := contracts.ScanApprovals(context.Background(), c, []string{account}, nil, 0)
+	if err != nil || len(approvals) != 2 {
+		t.Errorf("Expect 2 approvals but %d, error: %v", len(approvals), err)
+		return
+	}
+
+	if approvals[0].Token != usdc.Hex() || approvals[0].Kind != contracts.APPROVAL_ERC20 || approvals[0].Allowance.Int64() != 100 || approvals[0].Spender != spender.Hex() {
+		t.Errorf("Erc20 approval not expected %v", approvals[0])
+	}
+
+	if approvals[1].Token != nft.Hex() || approvals[1].Kind != contracts.APPROVAL_OPERATOR || approvals[1].Allowance != nil || approvals[1].Block != 3 {
+		t.Errorf("Operator approval not expected %v", approvals[1])
+	}
+
+	path := "./approvals.xlsx"
+	defer os.Remove(path)
+	err = contracts.SaveApprovalFile(approvals, path)
+	if err != nil {
+		t.Errorf("Save approvals failed with error: %v", err)
+		return
+	}
+
+	list, err := contracts.ReadApprovalFile(path)
+	if err != nil || len(list) != 2 || list[0].Allowance.Int64() != 100 || list[1].Kind != contracts.APPROVAL_OPERATOR || list[1].Block != 3 {
+		t.Errorf("Read approvals not expected %v, error: %v", list, err)
+	}
+
+	// only owner can revoke
+	key, _ := crypto.GenerateKey()
+	other := wallet.NewWallet(wallet.WALLET_ETH, "", "")
+	other.SetPrivateKey(hexutil.Encode(crypto.FromECDSA(key)))
+	if _, err = contracts.RevokeApproval(context.Background(), c, approvals[0], other); err == nil {
+		t.Errorf("Expect error of revoking by other wallet")
+	}
+}
@@ -2,9 +2,11 @@ package tests
 
 import (
 	"math/big"
+	"os"
 	"testing"
 	"utopia/internal/helper"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -36,3 +38,122 @@ func TestHelper(t *testing.T) {
 		return
 	}
 }
+
+func TestAmount(t *testing.T) {
+	cases := []struct {
+		amount   string
+		decimals int
+		value    string
+	}{
+		{"1.5", 18, "1500000000000000000"},
+		{"0.00000001", 8, "1"},
+		{"21", 8, "2100000000"},
+		{"-0.25", 2, "-25"},
+		{"0", 18, "0"},
+	}
+
+	for _, c := range cases {
+		value, err := helper.ParseAmount(c.amount, c.decimals)
+		if err != nil || value.String() != c.value {
+			t.Errorf("Parse %s expect %s but %v %v", c.amount, c.value, value, err)
+			continue
+		}
+
+		if helper.FormatAmount(value, c.decimals) != c.amount {
+			t.Errorf("Format %s expect %s but %s", c.value, c.amount, helper.FormatAmount(value, c.decimals))
+		}
+	}
+
+	_, err := helper.ParseAmount("0.123", 2)
+	if err == nil {
+		t.Errorf("Expect decimals error but nil")
+	}
+}
+
+func TestType2Str(t *testing.T) {
+	tuple := struct {
+		X uint64
+		Y string
+	}{7, "seven"}
+
+	inputs := []interface{}{uint8(8), int64(-1), true, [4]byte{1, 2, 3, 4}, [][]*big.Int{{big.NewInt(1)}, {big.NewInt(2), big.NewInt(3)}}, tuple}
+	expects := []string{"8", "-1", "true", "0x01020304", "[[1],[2,3]]", "(7,seven)"}
+	for i, input := range inputs {
+		output, err := helper.Type2Str(input)
+		if err != nil || output != expects[i] {
+			t.Errorf("Type2Str expect %s but %s, error: %v", expects[i], output, err)
+		}
+	}
+
+	// uint8[] is list of number but bytes is hex
+	list, _ := abi.NewType("uint8[]", "", nil)
+	data, _ := abi.NewType("bytes", "", nil)
+	outputs, err := helper.Outputs2Str(abi.Arguments{{Type: list}, {Type: data}}, []interface{}{[]uint8{1, 2}, []byte{1, 2}})
+	if err != nil || len(outputs) != 2 || outputs[0] != "[1,2]" || outputs[1] != "0x0102" {
+		t.Errorf("Outputs2Str not expected %v, error: %v", outputs, err)
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	method, args, err := helper.ParseParams(`f(0x01, [[1,2],[3]], (-5,"a,(b)"), [0x01,0x02])`)
+	if err != nil || method != "f" || len(args) != 4 || args[2] != `(-5,"a,(b)")` {
+		t.Errorf("Parse params not expected %s %v, error: %v", method, args, err)
+		return
+	}
+
+	types := []string{"bytes4", "uint[][]", "(int8,string)", "bytes[2]"}
+	expects := []string{"bytes4", "uint256[][]", "(int8,string)", "bytes[2]"}
+	for i, item := range types {
+		typ, err := helper.ParseType(item)
+		if err != nil || typ.String() != expects[i] {
+			t.Errorf("Parse type %s not expected %s, error: %v", item, typ.String(), err)
+			return
+		}
+
+		value, err := helper.Str2Value(args[i], typ)
+		if err != nil {
+			t.Errorf("Parse value %s failed with error: %v", args[i], err)
+			return
+		}
+
+		// pack and unpack to check the value
+		arguments := abi.Arguments{{Type: typ}}
+		data, err := arguments.Pack(value)
+		if err != nil {
+			t.Errorf("Pack value %s failed with error: %v", args[i], err)
+			return
+		}
+
+		unpacked, _ := arguments.Unpack(data)
+		outputs, _ := helper.Outputs2Str(arguments, unpacked)
+		if len(outputs) != 1 || outputs[0] != []string{"0x01000000", "[[1,2],[3]]", "(-5,a,(b))", "[0x01,0x02]"}[i] {
+			t.Errorf("Value %s not expected %v", args[i], outputs)
+		}
+	}
+
+	// out of range, wrong size of fixed array and invalid address
+	invalids := map[string]string{"uint8": "256", "int8": "-129", "uint256[2]": "[1]", "address": "0x123", "bytes2": "0x010203"}
+	for item, arg := range invalids {
+		typ, _ := helper.ParseType(item)
+		if _, err := helper.Str2Value(arg, typ); err == nil {
+			t.Errorf("Expect error of %s for %s", arg, item)
+		}
+	}
+}
+
+func TestTokenTransferFile(t *testing.T) {
+	path := "./tokentransfer.xlsx"
+	defer os.Remove(path)
+
+	list := []helper.TokenTransferInfo{{From: "0x01", To: "0x02", Id: "7", Value: "3"}, {From: "0x01", To: "0x03", Id: "0x08", Value: "1", Notes: "gift"}}
+	err := helper.SaveTokenTransferFile(list, path)
+	if err != nil {
+		t.Errorf("Save token transfer file failed with error: %v", err)
+		return
+	}
+
+	result, err := helper.ReadTokenTransferFile(path)
+	if err != nil || len(result) != 2 || result[1].Id != "0x08" || result[1].Notes != "gift" || result[0].Notes != "x" {
+		t.Errorf("Read token transfer file not expected %v, error: %v", result, err)
+	}
+}